@@ -0,0 +1,152 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// alsaDefaultRate is used for ALSA devices, which do not report a rate
+	// when listed. The plug layer converts it if the hardware differs.
+	alsaDefaultRate = 48000
+	// alsaDefaultDevice is the ALSA default PCM
+	alsaDefaultDevice = "default"
+	// alsaProcPCM lists the PCM devices of all sound cards
+	alsaProcPCM = "/proc/asound/pcm"
+)
+
+// alsaCapturePlugins are the PCM types listed by arecord -L that can capture.
+// Others, such as surround51, hdmi and iec958, are playback only.
+var alsaCapturePlugins = []string{"default", "sysdefault", "hw", "plughw", "dsnoop", "pulse", "pipewire"}
+
+// parseArecordDevices parses the output of arecord -L. Each PCM name starts a
+// line and is followed by indented description lines. arecord lists playback
+// PCMs too, so only PCMs of capture capable types are kept.
+func parseArecordDevices(output []byte) []Device {
+	var devices []Device
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(devices) > 0 {
+				dev := &devices[len(devices)-1]
+				dev.Description = strings.TrimPrefix(dev.Description+", "+strings.TrimSpace(line), ", ")
+			}
+			continue
+		}
+		devices = append(devices, Device{Name: strings.TrimSpace(line)})
+	}
+
+	devices = slices.DeleteFunc(devices, func(dev Device) bool {
+		plugin, _, _ := strings.Cut(dev.Name, ":")
+		return !slices.Contains(alsaCapturePlugins, plugin)
+	})
+	return finishALSADevices(devices)
+}
+
+// parseProcPCMDevices parses /proc/asound/pcm, where each line looks like
+// "00-00: ALC3246 Analog : ALC3246 Analog : playback 1 : capture 1"
+func parseProcPCMDevices(output []byte) []Device {
+	var devices []Device
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || !strings.Contains(scanner.Text(), "capture") {
+			continue
+		}
+		card, dev, ok := strings.Cut(strings.TrimSpace(fields[0]), "-")
+		if !ok {
+			continue
+		}
+		cardNum, err1 := strconv.Atoi(card)
+		devNum, err2 := strconv.Atoi(dev)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		devices = append(devices, Device{
+			// The plug layer converts rate, channels and format as needed
+			Name:        fmt.Sprintf("plughw:%d,%d", cardNum, devNum),
+			Description: strings.TrimSpace(fields[1]),
+		})
+	}
+	return finishALSADevices(devices)
+}
+
+// finishALSADevices marks the ALSA devices as capture devices, and the
+// default PCM, or else the first device, as the current source
+func finishALSADevices(devices []Device) []Device {
+	current := 0
+	for i := range devices {
+		devices[i].Mode = DeviceFlagSource
+		devices[i].SampleRate = alsaDefaultRate
+		devices[i].Index = i
+		if devices[i].Name == alsaDefaultDevice {
+			current = i
+		}
+	}
+	if len(devices) > 0 {
+		devices[current].Mode |= DeviceFlagCurrentSource
+	}
+	return devices
+}
+
+// newALSADeviceLister creates a DeviceLister for ALSA capture devices using
+// arecord -L, or /proc/asound when arecord is not installed
+func newALSADeviceLister(ctx context.Context) (*DeviceLister, error) {
+	output, err := exec.CommandContext(ctx, "arecord", "-L").Output()
+	if err == nil {
+		return &DeviceLister{devices: parseArecordDevices(output)}, nil
+	}
+	output, procErr := os.ReadFile(alsaProcPCM)
+	if procErr != nil {
+		return nil, fmt.Errorf("arecord -L: %w", ErrCommandExecutionFailed)
+	}
+	return &DeviceLister{devices: parseProcPCMDevices(output)}, nil
+}
+
+// alsaSampleFormats maps bit depths to arecord sample formats. 24-bit
+// samples are packed in 3 bytes.
+var alsaSampleFormats = map[int]string{
+	8:  "S8",
+	16: "S16_LE",
+	24: "S24_3LE",
+	32: "S32_LE",
+}
+
+// newALSARecorder creates a recorder that captures raw PCM from an ALSA device with arecord
+func newALSARecorder(log *slog.Logger, args RecorderArgs) (Recorder, error) {
+	sampleFormat, ok := alsaSampleFormats[args.BitDepth]
+	if !ok {
+		return nil, fmt.Errorf("alsa %d-bit samples: %w", args.BitDepth, ErrUnsupportedSampleFormat)
+	}
+	format := WavFormat{
+		SampleRate: args.Device.SampleRate,
+		Channels:   args.Channels,
+		BitDepth:   args.BitDepth,
+	}
+	if format.SampleRate <= 0 {
+		format.SampleRate = alsaDefaultRate
+	}
+
+	cmdArgs := []string{
+		"-q",
+		"-D", args.Device.Name,
+		"-t", "raw",
+		"-f", sampleFormat,
+		"-r", strconv.Itoa(format.SampleRate),
+		"-c", strconv.Itoa(format.Channels),
+	}
+	// arecord writes to stdout when no file is given
+	return newCommandRecorder(log, args.Device, format, "arecord", cmdArgs...), nil
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -62,11 +65,7 @@ func GetDefaultSink() (Device, error) {
 }
 
 // GetDefaultSource returns the current default source device
-func GetDefaultSource(spDevices []Device) (Device, error) {
-	devices, err := ListDevices()
-	if err != nil {
-		return Device{}, err
-	}
+func GetDefaultSource(devices []Device) (Device, error) {
 	for _, dev := range devices {
 		if dev.Mode&DeviceFlagCurrentSource != 0 {
 			return dev, nil
@@ -75,98 +74,249 @@ func GetDefaultSource(spDevices []Device) (Device, error) {
 	return Device{}, ErrNoDefaultDevice
 }
 
-// GetDevice returns the device with the specified name
-func GetDevice(name string, spDevices []Device) (Device, error) {
-	devices, err := ListDevices()
-	if err != nil {
-		return Device{}, err
-	}
+// GetDevice returns the device with the specified name. Devices can also be
+// selected by their id or description.
+func GetDevice(name string, devices []Device) (Device, error) {
 	for _, dev := range devices {
 		if dev.Name == name {
 			return dev, nil
 		}
 	}
+	if id, err := strconv.Atoi(name); err == nil {
+		for _, dev := range devices {
+			if dev.ID != 0 && dev.ID == id {
+				return dev, nil
+			}
+		}
+	}
+	for _, dev := range devices {
+		if dev.Description != "" && strings.EqualFold(dev.Description, name) {
+			return dev, nil
+		}
+	}
 	return Device{}, ErrNoDefaultDevice
 }
 
 // LimitedCaptureArgs holds the arguments for limited capture
 type LimitedCaptureArgs struct {
-	EnableAutoStop      bool
+	EnableAutoStop bool
+	// AutoStopEngine selects the speech detector, see NewSpeechDetector
+	AutoStopEngine      string
 	AutoStopThreshold   float64
 	AutoStopMinDuration time.Duration
-	Duration            time.Duration
-	Channels            int
-	BitDepth            int
-	Writer              io.WriteCloser
+	// AutoStopHysteresis is the drop in dB below the threshold that counts as silence
+	AutoStopHysteresis float64
+	// AutoStopNoiseMargin keeps the threshold this many dB above the noise floor, zero disables it
+	AutoStopNoiseMargin float64
+	// WaitForSpeech holds back the output until speech starts, keeping
+	// PreRoll of audio from before the start
+	WaitForSpeech bool
+	PreRoll       time.Duration
+	// MaxWait stops the capture if no speech has started in time, zero waits indefinitely
+	MaxWait time.Duration
+	// TrimSilence removes leading and trailing non-speech, keeping TrimPad around the speech
+	TrimSilence bool
+	TrimPad     time.Duration
+	// Pause discards the captured audio and suspends auto-stop while paused, nil disables pausing
+	Pause *PauseSwitch
+	// Meter receives a live level meter, typically a terminal, nil disables it
+	Meter    io.Writer
+	Duration time.Duration
+	Writer   io.WriteCloser
+}
+
+// CaptureStats holds statistics about a completed capture
+type CaptureStats struct {
+	// Duration is the total duration of captured audio
+	Duration time.Duration
+	// SpeechDuration is the duration of speech found by the auto-stop detector
+	SpeechDuration time.Duration
+	// Quality holds level and quality measurements of the captured audio
+	Quality QualityReport
 }
 
-// LimitedCapture captures raw audio from the device until auto-stop is detected or duration expires
+// LimitedCapture records raw audio with the recorder until auto-stop is detected or duration expires
 func LimitedCapture(
 	ctx context.Context,
 	logger *slog.Logger,
-	device Device,
+	recorder Recorder,
 	args LimitedCaptureArgs,
-) error {
+) (CaptureStats, error) {
 	defer func() { _ = args.Writer.Close() }()
+	pcm := recorder.Format()
 
-	// Set up capture I/O
+	// Set up capture I/O. All captured audio passes through the speech meter,
+	// the quality analyzer and the speech detector.
 	g := new(errgroup.Group)
-	captureReader, captureWriter := io.Pipe()
+	capturePipeReader, captureWriter := io.Pipe()
+	format := SignedPCM(pcm.BitDepth)
+	frameSize := pcm.blockAlign()
+	meter := NewSpeechMeter(frameSize, pcm.SampleRate)
+	analyzer := NewQualityAnalyzer(pcm.Channels, format, pcm.SampleRate)
+	captureReader := io.TeeReader(capturePipeReader, io.MultiWriter(meter, analyzer))
 
 	captureCtx, captureCancel := context.WithCancel(ctx)
 	defer captureCancel()
-	if args.EnableAutoStop {
-		// Set up auto-stop splitter
-		autoStopReader, autoStopWriter := io.Pipe()
-		splitter := NewSilenceSplitter(
-			ctx,
-			args.Channels,
-			args.BitDepth,
-			args.AutoStopThreshold,
-			args.AutoStopMinDuration,
-			device.SampleRate,
-			func(data []byte) {
-				_, _ = autoStopWriter.Write(data)
-				captureCancel()
-			},
-		)
 
-		g.Go(func() error {
-			defer func() { _ = autoStopWriter.Close() }()
-			_, err := io.Copy(args.Writer, autoStopReader)
-			return err
+	// In wait-for-speech mode and when trimming, output is held back by a
+	// gate that opens on the first speech. Trailing silence is trimmed by
+	// delaying the output until speech has ended.
+	var output io.Writer = args.Writer
+	var gate *PreRollGate
+	var trimmer *TrailingTrimmer
+	var speechStarted atomic.Bool
+	if args.WaitForSpeech || args.TrimSilence {
+		preRoll := args.PreRoll
+		if !args.WaitForSpeech {
+			preRoll = args.TrimPad
+		}
+		gate = NewPreRollGate(args.Writer, frameSize, pcm.SampleRate, preRoll)
+		output = gate
+	}
+	if args.TrimSilence {
+		delay := args.AutoStopMinDuration + preRollSlack
+		trimmer = NewTrailingTrimmer(output, frameSize, pcm.SampleRate, delay, args.TrimPad)
+		output = trimmer
+	}
+	if args.WaitForSpeech && args.MaxWait > 0 {
+		timer := time.AfterFunc(args.MaxWait, func() {
+			if !speechStarted.Load() {
+				logger.Debug("no speech before max wait", "max_wait", args.MaxWait)
+				captureCancel()
+			}
 		})
+		defer timer.Stop()
+	}
+
+	var levelMeter *LevelMeter
+	if args.Meter != nil {
+		levelMeter = NewLevelMeter(args.Meter, format, pcm.Channels, pcm.SampleRate)
+	}
 
-		g.Go(func() error {
-			defer func() { _ = autoStopWriter.Close() }()
-			_, err := io.Copy(splitter, captureReader)
-			splitter.Flush() // Flush any remaining data
+	// The detector always runs, since the speech duration is measured from its events
+	detector, err := NewSpeechDetector(SpeechDetectorArgs{
+		Engine:         args.AutoStopEngine,
+		Format:         format,
+		Channels:       pcm.Channels,
+		SampleRate:     pcm.SampleRate,
+		StartThreshold: toDBFS(args.AutoStopThreshold),
+		Hysteresis:     args.AutoStopHysteresis,
+		NoiseMargin:    args.AutoStopNoiseMargin,
+		Hangover:       args.AutoStopMinDuration,
+	}, func(event SpeechEvent) {
+		logger.Debug("speech event", "type", event.Type, "offset", event.Offset)
+		meter.Observe(event)
+		switch event.Type {
+		case SpeechStart:
+			if gate != nil && !speechStarted.Load() {
+				logger.Info("speech detected", "offset", event.Offset)
+				gate.Open(event.Offset)
+			}
+			if trimmer != nil {
+				trimmer.SpeechStarted()
+			}
+			speechStarted.Store(true)
+		case SpeechEnd:
+			if trimmer != nil && speechStarted.Load() {
+				trimmer.SpeechEnded(event.Offset)
+			}
+			// Stop once speech has ended. While waiting for speech,
+			// initial silence does not count.
+			if args.EnableAutoStop && (!args.WaitForSpeech || speechStarted.Load()) {
+				captureCancel()
+			}
+		}
+	})
+	if err != nil {
+		return CaptureStats{}, err
+	}
+	captureReader = io.TeeReader(captureReader, detector)
+	if levelMeter != nil && (args.EnableAutoStop || args.WaitForSpeech || args.TrimSilence) {
+		levelMeter.SetDetector(detector, args.WaitForSpeech)
+	}
+	if levelMeter != nil {
+		// The meter follows the detector so that the countdown is current
+		captureReader = io.TeeReader(captureReader, levelMeter)
+	}
+	g.Go(func() error {
+		if levelMeter != nil {
+			defer func() { _ = levelMeter.Close() }()
+		}
+		if _, err := io.Copy(output, captureReader); err != nil {
+			// Unblock the recording so that it fails too
+			_ = capturePipeReader.CloseWithError(err)
 			return err
-		})
-	} else {
-		g.Go(func() error {
-			_, err := io.Copy(args.Writer, captureReader)
+		}
+		if trimmer != nil {
+			if err := trimmer.Close(); err != nil {
+				return err
+			}
+		}
+		// Without speech there is nothing to trim around, so the audio kept
+		// by the gate is written. Only the end of the capture was kept.
+		if gate != nil && !args.WaitForSpeech && !gate.Opened() {
+			err := gate.Flush()
+			logger.Info("no speech detected, keeping the end of the capture", "dropped", gate.Dropped())
 			return err
-		})
+		}
+		return nil
+	})
+
+	// Paused audio is dropped before it reaches the detector, so silence
+	// while paused does not count towards auto-stop
+	var captureOutput io.Writer = captureWriter
+	var pauser *pauseWriter
+	if args.Pause != nil {
+		pauser = newPauseWriter(captureWriter, args.Pause, frameSize)
+		captureOutput = pauser
 	}
 
-	// Capture audio until duration is finished.
+	// Capture audio until duration is finished. The recorder stops when the
+	// capture context is cancelled.
+	if err := recorder.Start(captureCtx); err != nil {
+		_ = captureWriter.Close()
+		return CaptureStats{}, err
+	}
 	logger.Info("audio capture started")
-	err := CaptureAudio(captureCtx, logger, CaptureArgs{
-		Device:   device,
-		Duration: args.Duration,
-		Output:   captureWriter,
-		Channels: args.Channels,
-		BitDepth: args.BitDepth,
-	})
-	_ = captureWriter.Close()
+	if args.Duration > 0 {
+		timer := time.AfterFunc(args.Duration, captureCancel)
+		defer timer.Stop()
+	}
+	_, err = io.Copy(captureOutput, recorder)
+	// A nil error is seen as the end of the stream
+	_ = captureWriter.CloseWithError(err)
 	if err != nil {
-		return err
+		_ = recorder.Stop()
 	}
 
 	if err := g.Wait(); err != nil {
-		return err
+		return CaptureStats{}, err
+	}
+	if args.WaitForSpeech && !speechStarted.Load() {
+		return CaptureStats{}, ErrSpeechTimeout
+	}
+
+	stats := CaptureStats{
+		Duration:       meter.Duration(),
+		SpeechDuration: meter.SpeechDuration(),
+		Quality:        analyzer.Report(),
+	}
+	if args.TrimSilence {
+		logger.Debug("trimmed silence",
+			"leading", gate.Dropped(),
+			"trailing", trimmer.Dropped())
+	}
+	if pauser != nil {
+		logger.Debug("discarded paused audio", "duration", framesToDuration(pauser.dropped, pcm.SampleRate))
 	}
+	logger.Debug("audio capture finished",
+		"duration", stats.Duration,
+		"speech_duration", stats.SpeechDuration,
+		"peak_dbfs", stats.Quality.PeakDBFS,
+		"rms_dbfs", stats.Quality.RMSDBFS,
+		"clipping_percent", stats.Quality.ClippingPercent,
+		"dc_offset", stats.Quality.DCOffset,
+		"snr_db", stats.Quality.SNRDB)
 
-	return nil
+	return stats, nil
 }
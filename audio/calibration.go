@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// calibrationHysteresis matches the default auto-stop hysteresis and is
+	// used to find pauses in the speech recording
+	calibrationHysteresis = 6
+	// calibrationMinMargin is the smallest distance in dB between the
+	// recommended threshold and the room tone or speech levels
+	calibrationMinMargin = 6
+	// calibrationMinSNR is the smallest speech to noise ratio in dB that can be calibrated
+	calibrationMinSNR = 3
+)
+
+// LevelRecorder implements io.Writer and records the RMS level of each
+// window of a raw PCM stream
+type LevelRecorder struct {
+	decoder    *frameDecoder
+	sampleRate int
+	windowSize int
+	windowPos  int
+	windowSum  float64
+	levels     []float64
+}
+
+// NewLevelRecorder creates a new LevelRecorder using the default detector window
+func NewLevelRecorder(format SampleFormat, channels, sampleRate int) *LevelRecorder {
+	return &LevelRecorder{
+		decoder:    newFrameDecoder(format, channels),
+		sampleRate: sampleRate,
+		windowSize: windowFrames(0, sampleRate),
+	}
+}
+
+// Write implements io.Writer
+func (r *LevelRecorder) Write(p []byte) (n int, err error) {
+	r.decoder.decode(p, func(frame []float64) {
+		for _, val := range frame {
+			r.windowSum += val * val
+		}
+		r.windowPos++
+		if r.windowPos == r.windowSize {
+			level := toDBFS(math.Sqrt(r.windowSum / float64(r.windowSize*len(frame))))
+			r.levels = append(r.levels, max(level, detectorFloorDBFS))
+			r.windowPos = 0
+			r.windowSum = 0
+		}
+	})
+	return len(p), nil
+}
+
+// Levels returns the RMS level in dBFS of each complete window
+func (r *LevelRecorder) Levels() []float64 {
+	return r.levels
+}
+
+// Window returns the duration of a window
+func (r *LevelRecorder) Window() time.Duration {
+	return framesToDuration(r.windowSize, r.sampleRate)
+}
+
+// Calibration holds measured levels and recommended auto-stop settings
+type Calibration struct {
+	// NoiseFloorDBFS is the median level of the room tone
+	NoiseFloorDBFS float64
+	// NoisePeakDBFS is the 95th percentile level of the room tone
+	NoisePeakDBFS float64
+	// SpeechDBFS is the 90th percentile level of the speech recording
+	SpeechDBFS float64
+	// SNRDB is the difference between the speech level and the noise floor
+	SNRDB float64
+	// ThresholdDBFS is the recommended speech start level
+	ThresholdDBFS float64
+	// Threshold is ThresholdDBFS relative to full scale, as used by --auto-stop-threshold
+	Threshold float64
+	// LongestPause is the longest pause between words in the speech recording
+	LongestPause time.Duration
+	// MinDuration is the recommended --auto-stop-min-duration
+	MinDuration time.Duration
+}
+
+// Calibrate recommends auto-stop settings from window levels of a room tone
+// recording and a speech recording
+func Calibrate(noise, speech []float64, window time.Duration) (Calibration, error) {
+	const minWindows = 10
+	if len(noise) < minWindows || len(speech) < minWindows {
+		return Calibration{}, fmt.Errorf("recordings are too short: %w", ErrCalibrationFailed)
+	}
+
+	var c Calibration
+	c.NoiseFloorDBFS = percentile(noise, 0.5)
+	c.NoisePeakDBFS = percentile(noise, 0.95)
+	c.SpeechDBFS = percentile(speech, 0.9)
+	c.SNRDB = c.SpeechDBFS - c.NoiseFloorDBFS
+	if c.SpeechDBFS-c.NoisePeakDBFS < calibrationMinSNR {
+		return c, fmt.Errorf("speech (%.1f dBFS) is not louder than the room tone (%.1f dBFS): %w",
+			c.SpeechDBFS, c.NoisePeakDBFS, ErrCalibrationFailed)
+	}
+
+	// Place the threshold a third of the way from the noise to the speech,
+	// keeping a margin to both where possible
+	threshold := c.NoisePeakDBFS + (c.SpeechDBFS-c.NoisePeakDBFS)/3
+	threshold = max(threshold, c.NoisePeakDBFS+calibrationMinMargin)
+	threshold = min(threshold, c.SpeechDBFS-calibrationMinMargin)
+	c.ThresholdDBFS = threshold
+	c.Threshold = math.Pow(10, threshold/20)
+
+	// Find the longest pause between the first and last speech windows
+	end := threshold - calibrationHysteresis
+	isSpeech := func(l float64) bool { return l >= threshold }
+	first := slices.IndexFunc(speech, isSpeech)
+	last := first
+	for i := first; i < len(speech); i++ {
+		if isSpeech(speech[i]) {
+			last = i
+		}
+	}
+	longest, run := 0, 0
+	for _, level := range speech[first : last+1] {
+		if level < end {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	c.LongestPause = time.Duration(longest) * window
+
+	// Allow half again the longest pause plus a margin, within sane bounds
+	minDuration := c.LongestPause*3/2 + 300*time.Millisecond
+	c.MinDuration = min(max(minDuration.Round(100*time.Millisecond), 600*time.Millisecond), 3*time.Second)
+	return c, nil
+}
+
+// percentile returns the p-th percentile (0-1) of values
+func percentile(values []float64, p float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[min(int(p*float64(len(sorted))), len(sorted)-1)]
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Output containers
+const (
+	ContainerWAV  = "wav"
+	ContainerFLAC = "flac"
+	ContainerOgg  = "ogg"
+	ContainerMP3  = "mp3"
+	ContainerRaw  = "raw"
+)
+
+// Output codecs
+const (
+	CodecPCM    = "pcm"
+	CodecFLAC   = "flac"
+	CodecOpus   = "opus"
+	CodecVorbis = "vorbis"
+	CodecMP3    = "mp3"
+)
+
+// containerCodecs lists the codecs each container can hold, starting with its default
+var containerCodecs = map[string][]string{
+	ContainerWAV:  {CodecPCM},
+	ContainerFLAC: {CodecFLAC},
+	ContainerOgg:  {CodecVorbis, CodecOpus, CodecFLAC},
+	ContainerMP3:  {CodecMP3},
+	ContainerRaw:  {CodecPCM},
+}
+
+// fileExtensions maps file extensions to a container and, if the extension
+// names one, a codec
+var fileExtensions = map[string][2]string{
+	".wav":  {ContainerWAV, ""},
+	".flac": {ContainerFLAC, ""},
+	".ogg":  {ContainerOgg, ""},
+	".oga":  {ContainerOgg, ""},
+	".opus": {ContainerOgg, CodecOpus},
+	".mp3":  {ContainerMP3, ""},
+	".raw":  {ContainerRaw, ""},
+	".pcm":  {ContainerRaw, ""},
+}
+
+// FormatFromPath returns the container implied by the extension of path, and
+// the codec if the extension names one. It reports false for unknown extensions.
+func FormatFromPath(path string) (container, codec string, ok bool) {
+	format, ok := fileExtensions[strings.ToLower(filepath.Ext(path))]
+	return format[0], format[1], ok
+}
+
+// ResolveCodec returns the codec used for a container, which is the default
+// codec of the container if codec is empty
+func ResolveCodec(container, codec string) (string, error) {
+	codecs, ok := containerCodecs[container]
+	if !ok {
+		return "", fmt.Errorf("unknown container %q: %w", container, ErrInvalidCodecContainerCombination)
+	}
+	if codec == "" {
+		return codecs[0], nil
+	}
+	if !slices.Contains(codecs, codec) {
+		return "", fmt.Errorf("%s cannot hold %s (valid codecs: %s): %w",
+			container, codec, strings.Join(codecs, ", "), ErrInvalidCodecContainerCombination)
+	}
+	return codec, nil
+}
+
+// IsLossyCodec reports whether a codec discards audio, which makes its
+// bitrate and quality selectable
+func IsLossyCodec(codec string) bool {
+	switch codec {
+	case CodecOpus, CodecVorbis, CodecMP3:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// commandRecorder implements Recorder by running a command that writes raw PCM
+// to stdout, such as sox or pw-record. The command is stopped with SIGINT so
+// that it can flush its buffers.
+type commandRecorder struct {
+	log       *slog.Logger
+	name      string
+	args      []string
+	device    Device
+	format    WavFormat
+	cmd       *exec.Cmd
+	stdout    io.Reader
+	stderr    bytes.Buffer
+	stopped   atomic.Bool
+	stopAfter func() bool
+	waited    bool
+	waitErr   error
+	// errorMessage extracts the error message from the stderr output, if set
+	errorMessage func(stderr string) string
+}
+
+func newCommandRecorder(
+	log *slog.Logger,
+	device Device,
+	format WavFormat,
+	name string,
+	args ...string,
+) *commandRecorder {
+	return &commandRecorder{
+		log:    log,
+		name:   name,
+		args:   args,
+		device: device,
+		format: format,
+	}
+}
+
+// Start implements Recorder
+func (r *commandRecorder) Start(ctx context.Context) error {
+	r.log.DebugContext(ctx, "Running "+r.name,
+		"args", r.args,
+		"command", fmt.Sprintf("%s %s", r.name, strings.Join(r.args, " ")),
+		"device", r.device)
+
+	r.cmd = exec.Command(r.name, r.args...)
+	// Run the command in its own process group so that Ctrl-C in the
+	// terminal does not reach it directly. It is stopped with SIGINT by Stop.
+	r.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	r.cmd.Stderr = &r.stderr
+	stdout, err := r.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("%s start failed: %w", r.name, err)
+	}
+	r.stdout = stdout
+
+	if err := r.cmd.Start(); err != nil {
+		r.log.ErrorContext(ctx, "Recorder start failed",
+			"command", r.name,
+			"error", err,
+			"device", r.device)
+		return fmt.Errorf("%s start failed: %w", r.name, err)
+	}
+	r.stopAfter = context.AfterFunc(ctx, func() { _ = r.Stop() })
+	return nil
+}
+
+// Read implements Recorder
+func (r *commandRecorder) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if !errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	// The command has closed its output, so wait for it to exit
+	if !r.waited {
+		r.waited = true
+		r.stopAfter()
+		r.waitErr = r.cmd.Wait()
+	}
+	// Recorders exit with an error status when interrupted, which is expected after Stop
+	if r.waitErr != nil && !r.stopped.Load() {
+		r.log.Error("Recorder execution failed",
+			"command", r.name,
+			"error", r.waitErr,
+			"stderr", r.stderr.String(),
+			"device", r.device)
+		message := r.stderr.String()
+		if r.errorMessage != nil {
+			message = r.errorMessage(message)
+		}
+		return n, fmt.Errorf("%s capture failed: %s: %w", r.name, message, ErrAudioCaptureFailed)
+	}
+	return n, io.EOF
+}
+
+// Stop implements Recorder
+func (r *commandRecorder) Stop() error {
+	if r.cmd == nil || r.cmd.Process == nil || !r.stopped.CompareAndSwap(false, true) {
+		return nil
+	}
+	// Send SIGINT for graceful shutdown
+	err := r.cmd.Process.Signal(syscall.SIGINT)
+	if err == nil || errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	r.log.Error("Failed to send SIGINT to recorder", "command", r.name, "error", err)
+	// Fallback to kill
+	return r.cmd.Process.Kill()
+}
+
+// Format implements Recorder
+func (r *commandRecorder) Format() WavFormat {
+	return r.format
+}
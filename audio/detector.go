@@ -0,0 +1,267 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// detectorWindow is the default analysis window for speech detectors
+	detectorWindow = 20 * time.Millisecond
+	// detectorStartWindows is the number of consecutive speech windows
+	// required to start speech, so that short clicks are ignored
+	detectorStartWindows = 3
+	// detectorFloorDBFS is the lowest level tracked, used in place of digital silence
+	detectorFloorDBFS = -100
+	// noiseFloorRise and noiseFloorFall are the per-window smoothing factors
+	// for the noise floor estimate. The floor follows drops quickly and rises
+	// slowly so that speech does not pull it up.
+	noiseFloorRise = 0.01
+	noiseFloorFall = 0.5
+	// noiseFloorSpan is how far back the noise floor looks for the quietest
+	// window. It is longer than the usual gap between words.
+	noiseFloorSpan = 1500 * time.Millisecond
+)
+
+// Auto-stop detector engines
+const (
+	EngineEnergy = "energy"
+	EngineVAD    = "vad"
+)
+
+// SpeechEventType is the type of a SpeechEvent
+type SpeechEventType int
+
+const (
+	// SpeechStart is emitted when speech begins
+	SpeechStart SpeechEventType = iota
+	// SpeechEnd is emitted when speech has been followed by silence for the hangover time
+	SpeechEnd
+)
+
+// String returns the name of the event type
+func (t SpeechEventType) String() string {
+	switch t {
+	case SpeechStart:
+		return "speech-start"
+	case SpeechEnd:
+		return "speech-end"
+	default:
+		return "unknown"
+	}
+}
+
+// SpeechEvent is a change in speech activity
+type SpeechEvent struct {
+	Type SpeechEventType
+	// Offset is the position in the stream where speech started or ended
+	Offset time.Duration
+}
+
+// SpeechDetector is implemented by the auto-stop engines. Raw PCM is written
+// to the detector, which emits speech events to its callback.
+//
+// Speech starts after a few consecutive speech windows, and ends when no
+// speech has been seen for the hangover time. If no speech has started by the
+// end of the hangover time, SpeechEnd is emitted without a preceding
+// SpeechStart so that callers which stop on silence behave the same whether or
+// not anyone spoke.
+type SpeechDetector interface {
+	io.Writer
+	// Speaking reports whether speech is currently detected
+	Speaking() bool
+	// SilenceRemaining returns how much more silence ends the ongoing speech.
+	// It reports false unless speech is ongoing and silence is being counted.
+	SilenceRemaining() (time.Duration, bool)
+}
+
+// SpeechDetectorArgs holds the arguments for NewSpeechDetector
+type SpeechDetectorArgs struct {
+	// Engine is EngineEnergy or EngineVAD, defaults to EngineEnergy
+	Engine     string
+	Format     SampleFormat
+	Channels   int
+	SampleRate int
+	// StartThreshold is the RMS level in dBFS at which speech starts. The VAD
+	// treats anything quieter than the end threshold as silence.
+	StartThreshold float64
+	// Hysteresis is how many dB below the start threshold the level must fall
+	// before silence is counted
+	Hysteresis float64
+	// NoiseMargin raises the start threshold to this many dB above the
+	// estimated noise floor. Zero disables noise floor adaptation.
+	NoiseMargin float64
+	// Hangover is how long silence must last before speech ends
+	Hangover time.Duration
+}
+
+// NewSpeechDetector creates the detector for the selected engine
+func NewSpeechDetector(args SpeechDetectorArgs, callback func(SpeechEvent)) (SpeechDetector, error) {
+	switch args.Engine {
+	case EngineEnergy, "":
+		return NewEnergyDetector(EnergyDetectorArgs{
+			Format:         args.Format,
+			Channels:       args.Channels,
+			SampleRate:     args.SampleRate,
+			StartThreshold: args.StartThreshold,
+			Hysteresis:     args.Hysteresis,
+			NoiseMargin:    args.NoiseMargin,
+			Hangover:       args.Hangover,
+		}, callback), nil
+	case EngineVAD:
+		return NewVAD(VADArgs{
+			Format:     args.Format,
+			Channels:   args.Channels,
+			SampleRate: args.SampleRate,
+			MinLevel:   args.StartThreshold - args.Hysteresis,
+			Hangover:   args.Hangover,
+		}, callback), nil
+	default:
+		return nil, fmt.Errorf("auto-stop engine %q: %w", args.Engine, ErrUnknownDetectorEngine)
+	}
+}
+
+// speechTracker turns per-window speech decisions into speech events
+type speechTracker struct {
+	windowFrames int
+	sampleRate   int
+	hangover     int // windows
+	callback     func(SpeechEvent)
+	windows      int // windows analyzed
+	speaking     bool
+	startCount   int // consecutive windows that may start speech
+	silentCount  int // consecutive windows that do not sustain speech
+}
+
+func newSpeechTracker(
+	windowFrames, sampleRate int,
+	hangover time.Duration,
+	callback func(SpeechEvent),
+) *speechTracker {
+	windowDuration := framesToDuration(windowFrames, sampleRate)
+	return &speechTracker{
+		windowFrames: windowFrames,
+		sampleRate:   sampleRate,
+		hangover:     max(int(math.Ceil(float64(hangover)/float64(windowDuration))), 1),
+		callback:     callback,
+	}
+}
+
+// update accounts for a window. start reports whether the window is loud
+// enough to start speech, and sustain whether it is loud enough to keep
+// ongoing speech from ending.
+func (t *speechTracker) update(start, sustain bool) {
+	t.windows++
+
+	if !t.speaking {
+		if start {
+			t.startCount++
+		} else {
+			t.startCount = 0
+		}
+		if t.startCount >= detectorStartWindows {
+			t.speaking = true
+			t.silentCount = 0
+			t.emit(SpeechStart, t.windows-t.startCount)
+			return
+		}
+
+		// Report initial silence once, see the SpeechDetector docs
+		if t.windows == t.hangover {
+			t.emit(SpeechEnd, 0)
+		}
+		return
+	}
+
+	if sustain {
+		t.silentCount = 0
+		return
+	}
+	t.silentCount++
+	if t.silentCount >= t.hangover {
+		t.speaking = false
+		t.startCount = 0
+		t.emit(SpeechEnd, t.windows-t.silentCount)
+	}
+}
+
+// silenceRemaining implements SpeechDetector.SilenceRemaining
+func (t *speechTracker) silenceRemaining() (time.Duration, bool) {
+	if !t.speaking || t.silentCount == 0 {
+		return 0, false
+	}
+	return framesToDuration((t.hangover-t.silentCount)*t.windowFrames, t.sampleRate), true
+}
+
+// emit calls the callback with an event at the start of the given window
+func (t *speechTracker) emit(typ SpeechEventType, window int) {
+	if t.callback == nil {
+		return
+	}
+	t.callback(SpeechEvent{
+		Type:   typ,
+		Offset: framesToDuration(window*t.windowFrames, t.sampleRate),
+	})
+}
+
+// noiseFloor tracks the background level in dBFS. The estimate follows the
+// quietest of the recent windows, which is the background even during speech
+// since there are pauses between words.
+type noiseFloor struct {
+	// recent holds the levels of the windows in the last noiseFloorSpan
+	recent []float64
+	pos    int
+	filled int
+	level  float64
+	known  bool
+}
+
+func newNoiseFloor(windowFrames, sampleRate int) noiseFloor {
+	windows := int(noiseFloorSpan / max(framesToDuration(windowFrames, sampleRate), time.Millisecond))
+	return noiseFloor{recent: make([]float64, max(windows, 1))}
+}
+
+// update adds the level of a window to the estimate
+func (n *noiseFloor) update(level float64) {
+	n.recent[n.pos] = level
+	n.pos = (n.pos + 1) % len(n.recent)
+	n.filled = min(n.filled+1, len(n.recent))
+	quietest := slices.Min(n.recent[:n.filled])
+
+	if !n.known {
+		n.level, n.known = quietest, true
+		return
+	}
+	alpha := noiseFloorRise
+	if quietest < n.level {
+		alpha = noiseFloorFall
+	}
+	n.level += alpha * (quietest - n.level)
+}
+
+// value returns the estimate, or NaN if no window has been seen
+func (n *noiseFloor) value() float64 {
+	if !n.known {
+		return math.NaN()
+	}
+	return n.level
+}
+
+// windowFrames returns the number of frames in a detector window
+func windowFrames(window time.Duration, sampleRate int) int {
+	if window <= 0 {
+		window = detectorWindow
+	}
+	return max(int(window.Seconds()*float64(sampleRate)), 1)
+}
+
+// framesToDuration converts a frame count to a duration
+func framesToDuration(frames, sampleRate int) time.Duration {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(frames) / float64(sampleRate) * float64(time.Second))
+}
@@ -15,16 +15,20 @@ const (
 
 // Device represents an audio device with its name and mode flags
 type Device struct {
-	Name       string
-	Mode       uint
-	SampleRate int
-	Index      int // Index used by sox for coreaudio
+	Name        string
+	Description string // Human-readable name, if known
+	ID          int    // PipeWire node id, if known
+	Mode        uint
+	SampleRate  int
+	Index       int // Index used by sox for coreaudio
 }
 
 // MarshalJSON custom marshals Device to JSON with readable flags
 func (d Device) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]interface{}{
 		"name":           d.Name,
+		"description":    d.Description,
+		"id":             d.ID,
 		"source":         d.Mode&DeviceFlagSource != 0,
 		"sink":           d.Mode&DeviceFlagSink != 0,
 		"current_source": d.Mode&DeviceFlagCurrentSource != 0,
@@ -86,3 +86,8 @@ func (s *DeviceLister) GetDefaultDeviceName() string {
 	}
 	return ""
 }
+
+// defaultBackend returns the recorder backend for BackendAuto
+func defaultBackend() string {
+	return commandBackend()
+}
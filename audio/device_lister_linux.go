@@ -42,10 +42,11 @@ func parsePactlDevices(sourcesOutput, sinksOutput, defaultSource, defaultSink []
 		}
 
 		devices = append(devices, Device{
-			Name:       deviceName, // Use device name (not description) for sox
-			Mode:       mode,
-			SampleRate: sampleRate,
-			Index:      index,
+			Name:        deviceName, // Use device name (not description) for sox
+			Description: strings.TrimSpace(string(match[3])),
+			Mode:        mode,
+			SampleRate:  sampleRate,
+			Index:       index,
 		})
 		index++
 	}
@@ -70,10 +71,11 @@ func parsePactlDevices(sourcesOutput, sinksOutput, defaultSource, defaultSink []
 		}
 
 		devices = append(devices, Device{
-			Name:       deviceName, // Use device name (not description) for sox
-			Mode:       mode,
-			SampleRate: sampleRate,
-			Index:      index,
+			Name:        deviceName, // Use device name (not description) for sox
+			Description: strings.TrimSpace(string(match[3])),
+			Mode:        mode,
+			SampleRate:  sampleRate,
+			Index:       index,
 		})
 		index++
 	}
@@ -81,8 +83,13 @@ func parsePactlDevices(sourcesOutput, sinksOutput, defaultSource, defaultSink []
 	return devices, nil
 }
 
-// NewDeviceLister creates a new DeviceLister by running pactl
+// NewDeviceLister creates a new DeviceLister by running pactl. Without
+// PulseAudio, ALSA devices are listed instead.
 func NewDeviceLister(ctx context.Context) (*DeviceLister, error) {
+	if !hasPulseAudio() {
+		return newALSADeviceLister(ctx)
+	}
+
 	// Get sources
 	sourcesCmd := exec.CommandContext(ctx, "pactl", "list", "sources")
 	sourcesOutput, err := sourcesCmd.Output()
@@ -133,3 +140,18 @@ func (s *DeviceLister) GetDefaultDeviceName() string {
 	}
 	return ""
 }
+
+// hasPulseAudio reports whether pactl is installed
+func hasPulseAudio() bool {
+	_, err := exec.LookPath("pactl")
+	return err == nil
+}
+
+// defaultBackend returns the recorder backend for BackendAuto. sox and ffmpeg
+// record through PulseAudio, so machines without it record with ALSA.
+func defaultBackend() string {
+	if !hasPulseAudio() {
+		return BackendALSA
+	}
+	return commandBackend()
+}
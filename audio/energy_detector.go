@@ -0,0 +1,100 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// EnergyDetectorArgs holds the arguments for NewEnergyDetector
+type EnergyDetectorArgs struct {
+	Format     SampleFormat
+	Channels   int
+	SampleRate int
+	// Window is the RMS analysis window, defaults to 20ms
+	Window time.Duration
+	// StartThreshold is the RMS level in dBFS at which speech starts
+	StartThreshold float64
+	// Hysteresis is how many dB below the start threshold the level must fall
+	// before silence is counted
+	Hysteresis float64
+	// NoiseMargin raises the start threshold to this many dB above the
+	// estimated noise floor. Zero disables noise floor adaptation.
+	NoiseMargin float64
+	// Hangover is how long the level must stay below the end threshold before speech ends
+	Hangover time.Duration
+}
+
+// EnergyDetector implements SpeechDetector using the RMS level of fixed
+// windows. Speech starts above the start threshold and is sustained above the
+// end threshold.
+type EnergyDetector struct {
+	args       EnergyDetectorArgs
+	decoder    *frameDecoder
+	tracker    *speechTracker
+	windowSize int
+	windowPos  int
+	windowSum  float64
+	noise      noiseFloor
+}
+
+// NewEnergyDetector creates a new EnergyDetector that calls callback for each speech event
+func NewEnergyDetector(args EnergyDetectorArgs, callback func(SpeechEvent)) *EnergyDetector {
+	windowSize := windowFrames(args.Window, args.SampleRate)
+	return &EnergyDetector{
+		args:       args,
+		decoder:    newFrameDecoder(args.Format, args.Channels),
+		tracker:    newSpeechTracker(windowSize, args.SampleRate, args.Hangover, callback),
+		windowSize: windowSize,
+		noise:      newNoiseFloor(windowSize, args.SampleRate),
+	}
+}
+
+// Write implements io.Writer
+func (d *EnergyDetector) Write(p []byte) (n int, err error) {
+	d.decoder.decode(p, func(frame []float64) {
+		for _, val := range frame {
+			d.windowSum += val * val
+		}
+		d.windowPos++
+		if d.windowPos == d.windowSize {
+			level := toDBFS(math.Sqrt(d.windowSum / float64(d.windowSize*len(frame))))
+			d.analyzeWindow(max(level, detectorFloorDBFS))
+			d.windowPos = 0
+			d.windowSum = 0
+		}
+	})
+	return len(p), nil
+}
+
+// Speaking implements SpeechDetector
+func (d *EnergyDetector) Speaking() bool {
+	return d.tracker.speaking
+}
+
+// SilenceRemaining implements SpeechDetector
+func (d *EnergyDetector) SilenceRemaining() (time.Duration, bool) {
+	return d.tracker.silenceRemaining()
+}
+
+// Thresholds returns the current start and end thresholds in dBFS
+func (d *EnergyDetector) Thresholds() (start, end float64) {
+	start = d.args.StartThreshold
+	if floor := d.noise.value(); d.args.NoiseMargin > 0 && !math.IsNaN(floor) {
+		start = max(start, floor+d.args.NoiseMargin)
+	}
+	return start, start - d.args.Hysteresis
+}
+
+// NoiseFloor returns the estimated noise floor in dBFS, or NaN if not yet known
+func (d *EnergyDetector) NoiseFloor() float64 {
+	return d.noise.value()
+}
+
+// analyzeWindow updates the detector state with the level of a window
+func (d *EnergyDetector) analyzeWindow(level float64) {
+	// The floor is updated in every window, so that steady noise above the
+	// start threshold raises the threshold too
+	start, end := d.Thresholds()
+	d.noise.update(level)
+	d.tracker.update(level >= start, level >= end)
+}
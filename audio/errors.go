@@ -16,3 +16,33 @@ var ErrInvalidCodecContainerCombination = errors.New("invalid codec/container co
 
 // ErrAudioCaptureFailed indicates that audio capture failed
 var ErrAudioCaptureFailed = errors.New("audio capture failed")
+
+// ErrPoorAudioQuality indicates that the captured audio is below quality thresholds
+var ErrPoorAudioQuality = errors.New("poor audio quality")
+
+// ErrInvalidWav indicates that a stream is not a valid or supported WAV stream
+var ErrInvalidWav = errors.New("invalid wav")
+
+// ErrUnsupportedSampleFormat indicates that a sample format is not supported
+var ErrUnsupportedSampleFormat = errors.New("unsupported sample format")
+
+// ErrUnknownDetectorEngine indicates that the requested auto-stop engine does not exist
+var ErrUnknownDetectorEngine = errors.New("unknown detector engine")
+
+// ErrSpeechTimeout indicates that no speech started while waiting for speech
+var ErrSpeechTimeout = errors.New("timed out waiting for speech")
+
+// ErrCalibrationFailed indicates that the calibration recordings could not be used
+var ErrCalibrationFailed = errors.New("calibration failed")
+
+// ErrUnknownBackend indicates that the requested recorder backend does not exist
+var ErrUnknownBackend = errors.New("unknown recorder backend")
+
+// ErrInvalidSource indicates that a file, stdin or synthetic source cannot be used
+var ErrInvalidSource = errors.New("invalid audio source")
+
+// ErrUnsupportedConversion indicates that PCM cannot be converted between two formats
+var ErrUnsupportedConversion = errors.New("unsupported conversion")
+
+// ErrUnsupportedInput indicates that a file is not in a recognized audio or video format
+var ErrUnsupportedInput = errors.New("unsupported input format")
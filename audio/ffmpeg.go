@@ -0,0 +1,187 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ffmpegDefaultRate is used when the device does not report a rate
+const ffmpegDefaultRate = 48000
+
+// ffmpegGlobalArgs keep ffmpeg quiet except for errors and stop it from
+// reading the terminal, which is used for pause and push-to-talk keys
+var ffmpegGlobalArgs = []string{"-hide_banner", "-nostdin", "-loglevel", "error"}
+
+// ffmpegPCMFormats maps bit depths to ffmpeg raw signed little-endian PCM formats
+var ffmpegPCMFormats = map[int]string{
+	8:  "s8",
+	16: "s16le",
+	24: "s24le",
+	32: "s32le",
+}
+
+// ffmpegLogPrefix matches the component prefix of ffmpeg log lines, such as "[pulse @ 0x5581c0e0]"
+var ffmpegLogPrefix = regexp.MustCompile(`^\[[^\]]+ @ 0x[0-9a-f]+\]\s*`)
+
+// ffmpegErrorMessage returns the error lines of ffmpeg stderr output on one
+// line, without the component prefixes
+func ffmpegErrorMessage(stderr string) string {
+	var lines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(ffmpegLogPrefix.ReplaceAllString(strings.TrimSpace(line), ""))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// ffmpegRawArgs returns the ffmpeg format arguments for raw PCM
+func ffmpegRawArgs(sampleRate, channels, bitDepth int) ([]string, error) {
+	pcmFormat, ok := ffmpegPCMFormats[bitDepth]
+	if !ok {
+		return nil, fmt.Errorf("ffmpeg %d-bit samples: %w", bitDepth, ErrUnsupportedSampleFormat)
+	}
+	return []string{
+		"-f", pcmFormat,
+		"-ar", strconv.Itoa(sampleRate),
+		"-ac", strconv.Itoa(channels),
+	}, nil
+}
+
+// ffmpegEncoders maps codecs to ffmpeg audio encoders
+var ffmpegEncoders = map[string]string{
+	CodecFLAC:   "flac",
+	CodecOpus:   "libopus",
+	CodecVorbis: "libvorbis",
+	CodecMP3:    "libmp3lame",
+}
+
+// ffmpegTargetArgs returns the ffmpeg muxer, encoder and encoder settings of the target
+func ffmpegTargetArgs(args ConvertAudioArgs) ([]string, error) {
+	if args.TargetFormat == ContainerRaw {
+		return ffmpegRawArgs(args.SampleRate, args.Channels, args.BitDepth)
+	}
+
+	cmdArgs := []string{"-f", args.TargetFormat}
+	switch {
+	case args.Codec == CodecPCM && args.BitDepth == 8:
+		// 8-bit WAV is unsigned
+		cmdArgs = append(cmdArgs, "-c:a", "pcm_u8")
+	case args.Codec == CodecPCM:
+		cmdArgs = append(cmdArgs, "-c:a", fmt.Sprintf("pcm_s%dle", args.BitDepth))
+	case ffmpegEncoders[args.Codec] != "":
+		cmdArgs = append(cmdArgs, "-c:a", ffmpegEncoders[args.Codec])
+	}
+	if args.Bitrate > 0 {
+		cmdArgs = append(cmdArgs, "-b:a", fmt.Sprintf("%dk", args.Bitrate))
+	}
+	if args.Quality > 0 {
+		switch args.Codec {
+		case CodecVorbis:
+			cmdArgs = append(cmdArgs, "-q:a", strconv.Itoa(args.Quality))
+		case CodecMP3:
+			// LAME VBR quality, where 0 is the best
+			cmdArgs = append(cmdArgs, "-q:a", strconv.Itoa(10-args.Quality))
+		case CodecOpus:
+			cmdArgs = append(cmdArgs, "-compression_level", strconv.Itoa(args.Quality))
+		}
+	}
+	return cmdArgs, nil
+}
+
+// convertFFmpeg converts audio with ffmpeg. Formats are ffmpeg muxer and
+// demuxer names, and the output codec is the default codec of the muxer.
+// Source files are detected by ffmpeg, and only their first audio track is
+// converted.
+func convertFFmpeg(ctx context.Context, log *slog.Logger, args ConvertAudioArgs) error {
+	cmdArgs := append([]string{}, ffmpegGlobalArgs...)
+
+	// Source format
+	switch {
+	case args.SourcePath != "":
+		// The file protocol keeps names such as "pipe:1" from selecting another protocol
+		cmdArgs = append(cmdArgs, "-i", "file:"+args.SourcePath, "-map", "0:a:0")
+	case args.SourceFormat == "raw":
+		sourceArgs, err := ffmpegRawArgs(args.SampleRate, args.Channels, args.BitDepth)
+		if err != nil {
+			return err
+		}
+		cmdArgs = append(cmdArgs, sourceArgs...)
+		cmdArgs = append(cmdArgs, "-i", "pipe:0")
+	default:
+		cmdArgs = append(cmdArgs, "-f", args.SourceFormat, "-i", "pipe:0")
+	}
+
+	// Target format
+	targetArgs, err := ffmpegTargetArgs(args)
+	if err != nil {
+		return err
+	}
+	cmdArgs = append(cmdArgs, targetArgs...)
+	cmdArgs = append(cmdArgs, "pipe:1")
+
+	log.DebugContext(ctx, "Running ffmpeg convert",
+		"args", cmdArgs,
+		"command", fmt.Sprintf("ffmpeg %s", strings.Join(cmdArgs, " ")))
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", cmdArgs...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if args.SourcePath == "" {
+		cmd.Stdin = args.Reader
+	}
+	cmd.Stdout = args.Writer
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		log.ErrorContext(ctx, "FFmpeg convert failed",
+			"error", err,
+			"stderr", stderr.String())
+		message := ffmpegErrorMessage(stderr.String())
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			message = "ffmpeg is not installed"
+		case message == "":
+			message = err.Error()
+		}
+		return fmt.Errorf("ffmpeg convert failed: %s: %w", message, ErrAudioCaptureFailed)
+	}
+
+	return nil
+}
+
+// newFFmpegRecorder creates a recorder that runs ffmpeg with the audio input device of the platform
+func newFFmpegRecorder(log *slog.Logger, args RecorderArgs) (Recorder, error) {
+	format := WavFormat{
+		SampleRate: args.Device.SampleRate,
+		Channels:   args.Channels,
+		BitDepth:   args.BitDepth,
+	}
+	if format.SampleRate <= 0 {
+		format.SampleRate = ffmpegDefaultRate
+	}
+	outputArgs, err := ffmpegRawArgs(format.SampleRate, format.Channels, format.BitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdArgs := append([]string{}, ffmpegGlobalArgs...)
+	cmdArgs = append(cmdArgs, "-f", ffmpegInputFormat, "-i", ffmpegInputPrefix+args.Device.Name)
+	cmdArgs = append(cmdArgs, outputArgs...)
+	cmdArgs = append(cmdArgs, "pipe:1")
+
+	rec := newCommandRecorder(log, args.Device, format, "ffmpeg", cmdArgs...)
+	rec.errorMessage = ffmpegErrorMessage
+	return rec, nil
+}
@@ -0,0 +1,8 @@
+package audio
+
+// ffmpegInputFormat is the ffmpeg input device used to record from devices
+const ffmpegInputFormat = "avfoundation"
+
+// ffmpegInputPrefix is prepended to device names in ffmpeg input URLs. An
+// avfoundation URL is "video:audio", so audio-only devices start with a colon.
+const ffmpegInputPrefix = ":"
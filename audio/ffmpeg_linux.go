@@ -0,0 +1,7 @@
+package audio
+
+// ffmpegInputFormat is the ffmpeg input device used to record from devices
+const ffmpegInputFormat = "pulse"
+
+// ffmpegInputPrefix is prepended to device names in ffmpeg input URLs
+const ffmpegInputPrefix = ""
@@ -0,0 +1,788 @@
+package audio
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const (
+	// flacBlockSize is the number of frames per FLAC block
+	flacBlockSize = 4096
+	// flacMaxFixedOrder is the highest fixed predictor order
+	flacMaxFixedOrder = 4
+	// flacMaxLPCOrder is the highest LPC order tried when encoding
+	flacMaxLPCOrder = 8
+	// flacMaxPartitionOrder is the highest Rice partition order tried when encoding
+	flacMaxPartitionOrder = 6
+	// flacStreamInfoSize is the size of the STREAMINFO metadata block body
+	flacStreamInfoSize = 34
+)
+
+// FLAC channel assignments for stereo decorrelation
+const (
+	flacLeftSide  = 8
+	flacRightSide = 9
+	flacMidSide   = 10
+)
+
+// FlacWriter implements io.WriteCloser and encodes raw little-endian signed
+// PCM into a FLAC stream.
+//
+// Each block is encoded with the smallest of the constant, verbatim, fixed
+// and LPC subframe types, using Rice coded residuals and stereo decorrelation.
+// If the underlying writer is seekable, the STREAMINFO block is patched with
+// the total sample count, frame sizes and MD5 signature on Close. Otherwise
+// these fields are left as unknown, which decoders accept for streams.
+type FlacWriter struct {
+	w      io.Writer
+	seeker io.WriteSeeker
+	start  int64
+	format WavFormat
+
+	pending      []byte
+	samples      [][]int64
+	frameNumber  uint64
+	totalSamples uint64
+	minFrameSize int
+	maxFrameSize int
+	md5          hash.Hash
+}
+
+// NewFlacWriter writes the FLAC stream header to w and returns a writer for raw PCM data
+func NewFlacWriter(w io.Writer, format WavFormat) (*FlacWriter, error) {
+	// 32-bit FLAC is only supported by recent decoders
+	if format.Float || format.BitDepth%8 != 0 || format.BitDepth < 8 || format.BitDepth > 24 {
+		return nil, fmt.Errorf("flac: unsupported bit depth %d: %w", format.BitDepth, ErrUnsupportedSampleFormat)
+	}
+	if format.Channels < 1 || format.Channels > 8 {
+		return nil, fmt.Errorf("flac: unsupported channel count %d: %w", format.Channels, ErrUnsupportedSampleFormat)
+	}
+
+	fw := &FlacWriter{
+		w:       w,
+		format:  format,
+		samples: make([][]int64, format.Channels),
+		md5:     md5.New(),
+	}
+	for ch := range fw.samples {
+		fw.samples[ch] = make([]int64, flacBlockSize)
+	}
+
+	// Files are seekable, pipes such as stdout are not
+	if seeker, ok := w.(io.WriteSeeker); ok {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			fw.seeker = seeker
+			fw.start = pos
+		}
+	}
+
+	if _, err := w.Write(fw.header()); err != nil {
+		return nil, fmt.Errorf("write flac header: %w", err)
+	}
+	return fw, nil
+}
+
+// header returns the stream marker followed by the STREAMINFO metadata block
+func (w *FlacWriter) header() []byte {
+	var bw bitWriter
+	bw.writeBytes([]byte("fLaC"))
+	bw.writeBits(1, 1) // last metadata block
+	bw.writeBits(0, 7) // STREAMINFO
+	bw.writeBits(flacStreamInfoSize, 24)
+	blockSize := uint64(flacBlockSize)
+	if w.totalSamples > 0 && w.totalSamples < flacBlockSize {
+		// Streams shorter than a block consist of a single smaller block
+		blockSize = max(w.totalSamples, 16)
+	}
+	bw.writeBits(blockSize, 16) // min block size
+	bw.writeBits(blockSize, 16) // max block size
+	bw.writeBits(uint64(w.minFrameSize), 24)
+	bw.writeBits(uint64(w.maxFrameSize), 24)
+	bw.writeBits(uint64(w.format.SampleRate), 20)
+	bw.writeBits(uint64(w.format.Channels-1), 3)
+	bw.writeBits(uint64(w.format.BitDepth-1), 5)
+	bw.writeBits(w.totalSamples, 36)
+	if w.totalSamples > 0 {
+		bw.writeBytes(w.md5.Sum(nil))
+	} else {
+		bw.writeBytes(make([]byte, md5.Size))
+	}
+	return bw.bytes()
+}
+
+// Write implements io.Writer
+func (w *FlacWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+
+	blockBytes := flacBlockSize * w.format.blockAlign()
+	for len(w.pending) >= blockBytes {
+		if err := w.encodeBlock(w.pending[:blockBytes]); err != nil {
+			return 0, err
+		}
+		w.pending = w.pending[blockBytes:]
+	}
+	// Move the remainder to the start to keep the buffer from growing
+	w.pending = append(w.pending[:0:0], w.pending...)
+
+	return len(p), nil
+}
+
+// Close encodes any remaining samples and, when the underlying writer is
+// seekable, patches the STREAMINFO block. The underlying writer is not closed.
+func (w *FlacWriter) Close() error {
+	frames := len(w.pending) / w.format.blockAlign()
+	if frames > 0 {
+		if err := w.encodeBlock(w.pending[:frames*w.format.blockAlign()]); err != nil {
+			return err
+		}
+	}
+	w.pending = nil
+	if w.seeker == nil {
+		return nil
+	}
+
+	end, err := w.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek flac end: %w", err)
+	}
+	if _, err := w.seeker.Seek(w.start, io.SeekStart); err != nil {
+		return fmt.Errorf("seek flac header: %w", err)
+	}
+	if _, err := w.seeker.Write(w.header()); err != nil {
+		return fmt.Errorf("patch flac header: %w", err)
+	}
+	if _, err := w.seeker.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("seek flac end: %w", err)
+	}
+	return nil
+}
+
+// encodeBlock encodes interleaved PCM data as a single FLAC frame
+func (w *FlacWriter) encodeBlock(data []byte) error {
+	w.md5.Write(data)
+	bytesPerSample := w.format.BitDepth / 8
+	channels := w.format.Channels
+	n := len(data) / w.format.blockAlign()
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < channels; ch++ {
+			offset := (i*channels + ch) * bytesPerSample
+			w.samples[ch][i] = decodeSignedLE(data[offset : offset+bytesPerSample])
+		}
+	}
+
+	bps := w.format.BitDepth
+	plans := make([]subframe, channels)
+	assignment := channels - 1
+	if channels == 2 {
+		// Try all stereo decorrelation modes and keep the smallest
+		left, right := w.samples[0][:n], w.samples[1][:n]
+		mid := make([]int64, n)
+		side := make([]int64, n)
+		for i := range n {
+			mid[i] = (left[i] + right[i]) >> 1
+			side[i] = left[i] - right[i]
+		}
+		l := planSubframe(left, bps)
+		r := planSubframe(right, bps)
+		m := planSubframe(mid, bps)
+		s := planSubframe(side, bps+1)
+		plans[0], plans[1] = l, r
+		best := l.bits + r.bits
+		if size := l.bits + s.bits; size < best {
+			best, assignment = size, flacLeftSide
+			plans[0], plans[1] = l, s
+		}
+		if size := s.bits + r.bits; size < best {
+			best, assignment = size, flacRightSide
+			plans[0], plans[1] = s, r
+		}
+		if size := m.bits + s.bits; size < best {
+			assignment = flacMidSide
+			plans[0], plans[1] = m, s
+		}
+	} else {
+		for ch := range channels {
+			plans[ch] = planSubframe(w.samples[ch][:n], bps)
+		}
+	}
+
+	var bw bitWriter
+	w.writeFrameHeader(&bw, n, assignment)
+	for _, plan := range plans {
+		writeSubframe(&bw, plan)
+	}
+	bw.alignByte()
+	bw.writeBits(uint64(crc16(bw.bytes())), 16)
+
+	frame := bw.bytes()
+	if _, err := w.w.Write(frame); err != nil {
+		return fmt.Errorf("write flac frame: %w", err)
+	}
+	if w.minFrameSize == 0 || len(frame) < w.minFrameSize {
+		w.minFrameSize = len(frame)
+	}
+	w.maxFrameSize = max(w.maxFrameSize, len(frame))
+	w.totalSamples += uint64(n)
+	w.frameNumber++
+	return nil
+}
+
+// writeFrameHeader writes the frame header including its CRC-8
+func (w *FlacWriter) writeFrameHeader(bw *bitWriter, blockSize, assignment int) {
+	bw.writeBits(0x3FFE, 14) // sync code
+	bw.writeBits(0, 1)       // reserved
+	bw.writeBits(0, 1)       // fixed block size stream
+
+	var blockSizeCode uint64
+	switch {
+	case blockSize == flacBlockSize:
+		blockSizeCode = 12 // 256 * 2^(12-8)
+	case blockSize <= 256:
+		blockSizeCode = 6 // 8 bit block size at end of header
+	default:
+		blockSizeCode = 7 // 16 bit block size at end of header
+	}
+	bw.writeBits(blockSizeCode, 4)
+	sampleRateCode, sampleRateExtra, sampleRateBits := flacSampleRateCode(w.format.SampleRate)
+	bw.writeBits(sampleRateCode, 4)
+	bw.writeBits(uint64(assignment), 4)
+	bw.writeBits(flacSampleSizeCode(w.format.BitDepth), 3)
+	bw.writeBits(0, 1) // reserved
+	bw.writeBytes(utf8Number(w.frameNumber))
+	switch blockSizeCode {
+	case 6:
+		bw.writeBits(uint64(blockSize-1), 8)
+	case 7:
+		bw.writeBits(uint64(blockSize-1), 16)
+	}
+	if sampleRateBits > 0 {
+		bw.writeBits(sampleRateExtra, sampleRateBits)
+	}
+	bw.writeBits(uint64(crc8(bw.bytes())), 8)
+}
+
+// flacSampleRateCode returns the frame header sample rate code and any
+// value to be written at the end of the header
+func flacSampleRateCode(rate int) (code, extra uint64, extraBits uint) {
+	switch rate {
+	case 88200:
+		return 1, 0, 0
+	case 176400:
+		return 2, 0, 0
+	case 192000:
+		return 3, 0, 0
+	case 8000:
+		return 4, 0, 0
+	case 16000:
+		return 5, 0, 0
+	case 22050:
+		return 6, 0, 0
+	case 24000:
+		return 7, 0, 0
+	case 32000:
+		return 8, 0, 0
+	case 44100:
+		return 9, 0, 0
+	case 48000:
+		return 10, 0, 0
+	case 96000:
+		return 11, 0, 0
+	}
+	switch {
+	case rate%1000 == 0 && rate/1000 <= 255:
+		return 12, uint64(rate / 1000), 8
+	case rate <= 65535:
+		return 13, uint64(rate), 16
+	case rate%10 == 0 && rate/10 <= 65535:
+		return 14, uint64(rate / 10), 16
+	default:
+		return 0, 0, 0 // from STREAMINFO
+	}
+}
+
+// flacSampleSizeCode returns the frame header sample size code
+func flacSampleSizeCode(bitDepth int) uint64 {
+	switch bitDepth {
+	case 8:
+		return 1
+	case 16:
+		return 4
+	case 24:
+		return 6
+	default:
+		return 0 // from STREAMINFO
+	}
+}
+
+// utf8Number encodes a frame number using the UTF-8 like coding of the FLAC frame header
+func utf8Number(v uint64) []byte {
+	if v < 0x80 {
+		return []byte{byte(v)}
+	}
+	// Number of continuation bytes needed, each holding 6 bits
+	n := 1
+	for v >= 1<<(6*n+(6-n)) {
+		n++
+	}
+	res := make([]byte, n+1)
+	for i := n; i > 0; i-- {
+		res[i] = 0x80 | byte(v&0x3F)
+		v >>= 6
+	}
+	res[0] = byte(0xFF<<(7-n)) | byte(v)
+	return res
+}
+
+// decodeSignedLE decodes a little-endian signed integer of 1 to 3 bytes
+func decodeSignedLE(b []byte) int64 {
+	switch len(b) {
+	case 1:
+		return int64(int8(b[0]))
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	default:
+		return int64(int32(uint32(b[0])|uint32(b[1])<<8|uint32(b[2])<<16) << 8 >> 8)
+	}
+}
+
+// Subframe types
+const (
+	subframeConstant = iota
+	subframeVerbatim
+	subframeFixed
+	subframeLPC
+)
+
+// subframe describes how a block of samples from one channel is encoded
+type subframe struct {
+	kind      int
+	samples   []int64
+	bps       int
+	order     int
+	qcoefs    []int64
+	precision int
+	shift     int
+	residual  []int64
+	// bits is the estimated encoded size
+	bits int
+}
+
+// planSubframe chooses the subframe type with the smallest estimated size
+func planSubframe(samples []int64, bps int) subframe {
+	// Constant subframes for digital silence
+	constant := true
+	for _, v := range samples[1:] {
+		if v != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return subframe{kind: subframeConstant, samples: samples, bps: bps, bits: 8 + bps}
+	}
+
+	best := subframe{kind: subframeVerbatim, samples: samples, bps: bps, bits: 8 + len(samples)*bps}
+	residual := make([]int64, len(samples))
+	for order := 0; order <= flacMaxFixedOrder && order < len(samples); order++ {
+		if !fixedResidual(samples, order, residual) {
+			continue
+		}
+		bits := 8 + order*bps + residualBits(residual[order:], order, len(samples))
+		if bits < best.bits {
+			best = subframe{kind: subframeFixed, samples: samples, bps: bps, order: order, residual: residual, bits: bits}
+			residual = make([]int64, len(samples))
+		}
+	}
+
+	precision := 14
+	if bps > 16 {
+		precision = 15
+	}
+	for i, coefs := range lpcCoefficients(samples, flacMaxLPCOrder) {
+		order := i + 1
+		if order >= len(samples) {
+			break
+		}
+		qcoefs, shift := quantizeCoefficients(coefs, precision)
+		if !lpcResidual(samples, qcoefs, shift, residual) {
+			continue
+		}
+		bits := 8 + order*bps + 4 + 5 + order*precision + residualBits(residual[order:], order, len(samples))
+		if bits < best.bits {
+			best = subframe{
+				kind:      subframeLPC,
+				samples:   samples,
+				bps:       bps,
+				order:     order,
+				qcoefs:    qcoefs,
+				precision: precision,
+				shift:     shift,
+				residual:  residual,
+				bits:      bits,
+			}
+			residual = make([]int64, len(samples))
+		}
+	}
+	return best
+}
+
+// writeSubframe writes a planned subframe
+func writeSubframe(bw *bitWriter, sf subframe) {
+	bps := uint(sf.bps)
+	// Each header has a zero padding bit, six type bits and no wasted bits
+	switch sf.kind {
+	case subframeConstant:
+		bw.writeBits(0, 8)
+		bw.writeSigned(sf.samples[0], bps)
+	case subframeVerbatim:
+		bw.writeBits(0x01<<1, 8)
+		for _, v := range sf.samples {
+			bw.writeSigned(v, bps)
+		}
+	case subframeFixed:
+		bw.writeBits(uint64(0x08|sf.order)<<1, 8)
+		for _, v := range sf.samples[:sf.order] {
+			bw.writeSigned(v, bps)
+		}
+		writeResidual(bw, sf.residual[sf.order:], sf.order, len(sf.samples))
+	case subframeLPC:
+		bw.writeBits(uint64(0x20|(sf.order-1))<<1, 8)
+		for _, v := range sf.samples[:sf.order] {
+			bw.writeSigned(v, bps)
+		}
+		bw.writeBits(uint64(sf.precision-1), 4)
+		bw.writeSigned(int64(sf.shift), 5)
+		for _, c := range sf.qcoefs {
+			bw.writeSigned(c, uint(sf.precision))
+		}
+		writeResidual(bw, sf.residual[sf.order:], sf.order, len(sf.samples))
+	}
+}
+
+// fixedResidual computes the residual of a fixed predictor, reporting false
+// if the residual does not fit in 32 bits
+func fixedResidual(samples []int64, order int, residual []int64) bool {
+	for i := order; i < len(samples); i++ {
+		var r int64
+		switch order {
+		case 0:
+			r = samples[i]
+		case 1:
+			r = samples[i] - samples[i-1]
+		case 2:
+			r = samples[i] - 2*samples[i-1] + samples[i-2]
+		case 3:
+			r = samples[i] - 3*samples[i-1] + 3*samples[i-2] - samples[i-3]
+		case 4:
+			r = samples[i] - 4*samples[i-1] + 6*samples[i-2] - 4*samples[i-3] + samples[i-4]
+		}
+		if r > math.MaxInt32 || r < math.MinInt32 {
+			return false
+		}
+		residual[i] = r
+	}
+	return true
+}
+
+// lpcResidual computes the residual of a quantized LPC predictor, reporting
+// false if the residual does not fit in 32 bits
+func lpcResidual(samples, qcoefs []int64, shift int, residual []int64) bool {
+	order := len(qcoefs)
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range qcoefs {
+			sum += c * samples[i-j-1]
+		}
+		r := samples[i] - sum>>uint(shift)
+		if r > math.MaxInt32 || r < math.MinInt32 {
+			return false
+		}
+		residual[i] = r
+	}
+	return true
+}
+
+// lpcCoefficients computes linear prediction coefficients for all orders up
+// to maxOrder from the Tukey-windowed autocorrelation of the samples
+func lpcCoefficients(samples []int64, maxOrder int) [][]float64 {
+	n := len(samples)
+	maxOrder = min(maxOrder, n-1)
+	if maxOrder <= 0 {
+		return nil
+	}
+
+	windowed := make([]float64, n)
+	taper := n / 4 // Tukey window with alpha 0.5
+	for i, v := range samples {
+		w := 1.0
+		switch {
+		case i < taper:
+			w = 0.5 * (1 - math.Cos(math.Pi*float64(i)/float64(taper)))
+		case i >= n-taper:
+			w = 0.5 * (1 - math.Cos(math.Pi*float64(n-1-i)/float64(taper)))
+		}
+		windowed[i] = float64(v) * w
+	}
+
+	autoc := make([]float64, maxOrder+1)
+	for lag := range autoc {
+		var sum float64
+		for i := lag; i < n; i++ {
+			sum += windowed[i] * windowed[i-lag]
+		}
+		autoc[lag] = sum
+	}
+	if autoc[0] == 0 {
+		return nil
+	}
+
+	// Levinson-Durbin recursion
+	var res [][]float64
+	lpc := make([]float64, maxOrder)
+	errPower := autoc[0]
+	for i := 0; i < maxOrder; i++ {
+		r := -autoc[i+1]
+		for j := 0; j < i; j++ {
+			r -= lpc[j] * autoc[i-j]
+		}
+		r /= errPower
+
+		lpc[i] = r
+		for j := 0; j < i/2; j++ {
+			tmp := lpc[j]
+			lpc[j] += r * lpc[i-1-j]
+			lpc[i-1-j] += r * tmp
+		}
+		if i%2 == 1 {
+			lpc[i/2] += lpc[i/2] * r
+		}
+		errPower *= 1 - r*r
+		if errPower <= 0 {
+			break
+		}
+
+		coefs := make([]float64, i+1)
+		for j := range coefs {
+			coefs[j] = -lpc[j]
+		}
+		res = append(res, coefs)
+	}
+	return res
+}
+
+// quantizeCoefficients quantizes LPC coefficients to the given precision,
+// returning the coefficients and the shift to apply to the prediction
+func quantizeCoefficients(coefs []float64, precision int) ([]int64, int) {
+	var cmax float64
+	for _, c := range coefs {
+		cmax = max(cmax, math.Abs(c))
+	}
+	qmax := int64(1)<<(precision-1) - 1
+	qmin := -qmax - 1
+
+	shift := 15 // largest shift representable in the 5-bit signed field
+	if cmax > 0 {
+		_, exp := math.Frexp(cmax)
+		shift = min(max(precision-1-exp, 0), 15)
+	}
+
+	qcoefs := make([]int64, len(coefs))
+	var errAcc float64
+	for i, c := range coefs {
+		errAcc += c * float64(int64(1)<<shift)
+		q := int64(math.Round(errAcc))
+		q = min(max(q, qmin), qmax)
+		errAcc -= float64(q)
+		qcoefs[i] = q
+	}
+	return qcoefs, shift
+}
+
+// residualBits returns the estimated size of the Rice coded residual
+func residualBits(residual []int64, predictorOrder, blockSize int) int {
+	_, _, bits := bestPartitioning(residual, predictorOrder, blockSize)
+	return 6 + int(bits)
+}
+
+// bestPartitioning finds the Rice partition order and parameters that give
+// the smallest output, returning the zigzag encoded residual
+func bestPartitioning(residual []int64, predictorOrder, blockSize int) ([]uint64, []uint, uint64) {
+	zigzag := make([]uint64, len(residual))
+	for i, r := range residual {
+		zigzag[i] = uint64(r<<1) ^ uint64(r>>63)
+	}
+
+	bestBits := uint64(math.MaxUint64)
+	var bestParams []uint
+	for order := 0; order <= flacMaxPartitionOrder; order++ {
+		partitions := 1 << order
+		if blockSize%partitions != 0 || blockSize>>order <= predictorOrder {
+			break
+		}
+		params, bits := riceParameters(zigzag, order, predictorOrder, blockSize)
+		if bits < bestBits {
+			bestBits, bestParams = bits, params
+		}
+	}
+	return zigzag, bestParams, bestBits
+}
+
+// writeResidual writes the residual using partitioned Rice coding
+func writeResidual(bw *bitWriter, residual []int64, predictorOrder, blockSize int) {
+	zigzag, params, _ := bestPartitioning(residual, predictorOrder, blockSize)
+	partitionOrder := bits.TrailingZeros(uint(len(params)))
+
+	// Rice parameters above 14 require the 5-bit parameter coding method
+	method, paramBits, maxParam := uint64(0), uint(4), uint(14)
+	for _, k := range params {
+		if k > maxParam {
+			method, paramBits, maxParam = 1, 5, 30
+			break
+		}
+	}
+
+	bw.writeBits(method, 2)
+	bw.writeBits(uint64(partitionOrder), 4)
+	pos := 0
+	for p, k := range params {
+		k = min(k, maxParam)
+		n := blockSize >> partitionOrder
+		if p == 0 {
+			n -= predictorOrder
+		}
+		bw.writeBits(uint64(k), paramBits)
+		for _, u := range zigzag[pos : pos+n] {
+			bw.writeUnary(u >> k)
+			bw.writeBits(u, k)
+		}
+		pos += n
+	}
+}
+
+// riceParameters estimates the best Rice parameter for each partition and the
+// total number of bits needed for the residual
+func riceParameters(zigzag []uint64, order, predictorOrder, blockSize int) ([]uint, uint64) {
+	partitions := 1 << order
+	params := make([]uint, partitions)
+	var total uint64
+	pos := 0
+	for p := range partitions {
+		n := blockSize >> order
+		if p == 0 {
+			n -= predictorOrder
+		}
+		var sum uint64
+		for _, u := range zigzag[pos : pos+n] {
+			sum += u
+		}
+		pos += n
+
+		// The optimal parameter is close to log2 of the mean value
+		var k uint
+		if n > 0 {
+			for k < 30 && uint64(n)<<(k+1) < sum {
+				k++
+			}
+		}
+		params[p] = k
+		total += 5 + uint64(n)*(uint64(k)+1) + sum>>k
+	}
+	return params, total
+}
+
+// bitWriter writes big-endian bit sequences
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint // bits in acc, always less than 8 between writes
+}
+
+// writeBits writes the n least significant bits of v, most significant bit
+// first. n must be at most 56.
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.nbits))
+	}
+	w.acc &= 1<<w.nbits - 1
+}
+
+// writeSigned writes v as an n-bit two's complement integer
+func (w *bitWriter) writeSigned(v int64, n uint) {
+	w.writeBits(uint64(v)&(1<<n-1), n)
+}
+
+// writeUnary writes q zero bits followed by a one bit
+func (w *bitWriter) writeUnary(q uint64) {
+	for ; q >= 32; q -= 32 {
+		w.writeBits(0, 32)
+	}
+	w.writeBits(1, uint(q)+1)
+}
+
+// writeBytes writes whole bytes
+func (w *bitWriter) writeBytes(b []byte) {
+	if w.nbits == 0 {
+		w.buf = append(w.buf, b...)
+		return
+	}
+	for _, v := range b {
+		w.writeBits(uint64(v), 8)
+	}
+}
+
+// alignByte pads with zero bits up to the next byte boundary
+func (w *bitWriter) alignByte() {
+	if w.nbits > 0 {
+		w.writeBits(0, 8-w.nbits)
+	}
+}
+
+// len returns the number of bits written
+func (w *bitWriter) len() int {
+	return len(w.buf)*8 + int(w.nbits)
+}
+
+// bytes returns the complete bytes written so far
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
+
+// crc8 computes the FLAC frame header CRC-8 (polynomial 0x07)
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// crc16 computes the FLAC frame CRC-16 (polynomial 0x8005)
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+)
+
+// testPCM returns frames of signed little-endian PCM with a different tone
+// and some noise in each channel, so that all subframe types are exercised
+func testPCM(format WavFormat, frames int) []byte {
+	sample := SignedPCM(format.BitDepth)
+	size := sample.BytesPerSample()
+	data := make([]byte, frames*format.Channels*size)
+	seed := uint32(1)
+	for i := range frames {
+		for ch := range format.Channels {
+			seed = seed*1664525 + 1013904223
+			noise := float64(int32(seed)) / (1 << 31) * 0.05
+			v := 0.6*math.Sin(2*math.Pi*float64(i)*float64(220*(ch+1))/float64(format.SampleRate)) + noise
+			if i%1000 < 50 {
+				// Silent stretches produce constant subframes
+				v = 0
+			}
+			offset := (i*format.Channels + ch) * size
+			sample.Encode(data[offset:offset+size], v)
+		}
+	}
+	return data
+}
+
+// decodeFlac decodes a FLAC stream to signed little-endian PCM
+func decodeFlac(t *testing.T, r io.Reader) ([]byte, *flac.Stream) {
+	t.Helper()
+	stream, err := flac.New(r)
+	if err != nil {
+		t.Fatalf("open flac stream: %v", err)
+	}
+	size := int(stream.Info.BitsPerSample) / 8
+	var pcm []byte
+	for {
+		f, err := stream.ParseNext()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("parse flac frame: %v", err)
+		}
+		for i := range int(f.BlockSize) {
+			for _, sub := range f.Subframes {
+				var b [4]byte
+				binary.LittleEndian.PutUint32(b[:], uint32(sub.Samples[i]))
+				pcm = append(pcm, b[:size]...)
+			}
+		}
+	}
+	return pcm, stream
+}
+
+func Test_FlacWriter_roundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		channels int
+		bitDepth int
+		frames   int
+	}{
+		{"mono 8-bit", 1, 8, 4096},
+		{"mono 16-bit", 1, 16, 4095},
+		{"mono 24-bit", 1, 24, 4097},
+		{"stereo 8-bit", 2, 8, 4097},
+		{"stereo 16-bit", 2, 16, 4096},
+		{"stereo 24-bit", 2, 24, 4095},
+		{"stereo 16-bit several blocks", 2, 16, 3*4096 + 1},
+		{"empty", 1, 16, 0},
+	} {
+		format := WavFormat{SampleRate: 44100, Channels: tc.channels, BitDepth: tc.bitDepth}
+		pcm := testPCM(format, tc.frames)
+
+		t.Run(tc.name+" seekable", func(t *testing.T) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "test.flac"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			writeFlac(t, f, format, pcm)
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+
+			got, stream := decodeFlac(t, f)
+			if !bytes.Equal(got, pcm) {
+				t.Fatalf("decoded %d bytes that differ from the %d written", len(got), len(pcm))
+			}
+			checkStreamInfo(t, stream, format)
+			if stream.Info.NSamples != uint64(tc.frames) {
+				t.Errorf("STREAMINFO sample count %d, want %d", stream.Info.NSamples, tc.frames)
+			}
+			// The signature is left unset when there are no samples
+			want := md5.Sum(pcm)
+			if len(pcm) == 0 {
+				want = [md5.Size]byte{}
+			}
+			if stream.Info.MD5sum != want {
+				t.Errorf("STREAMINFO MD5 %x, want %x", stream.Info.MD5sum, want)
+			}
+		})
+
+		t.Run(tc.name+" stream", func(t *testing.T) {
+			var buf bytes.Buffer
+			writeFlac(t, &buf, format, pcm)
+
+			got, stream := decodeFlac(t, &buf)
+			if !bytes.Equal(got, pcm) {
+				t.Fatalf("decoded %d bytes that differ from the %d written", len(got), len(pcm))
+			}
+			checkStreamInfo(t, stream, format)
+			// Streams cannot be patched, so the count and signature are unknown
+			if stream.Info.NSamples != 0 || stream.Info.MD5sum != [md5.Size]byte{} {
+				t.Errorf("STREAMINFO has sample count %d and MD5 %x, want both unset",
+					stream.Info.NSamples, stream.Info.MD5sum)
+			}
+		})
+	}
+}
+
+func Test_NewFlacWriter_unsupportedFormat(t *testing.T) {
+	for _, format := range []WavFormat{
+		{SampleRate: 44100, Channels: 1, BitDepth: 32},
+		{SampleRate: 44100, Channels: 1, BitDepth: 32, Float: true},
+		{SampleRate: 44100, Channels: 9, BitDepth: 16},
+	} {
+		if _, err := NewFlacWriter(io.Discard, format); !errors.Is(err, ErrUnsupportedSampleFormat) {
+			t.Errorf("%+v: got error %v, want ErrUnsupportedSampleFormat", format, err)
+		}
+	}
+}
+
+// writeFlac writes pcm to w in uneven chunks, which do not line up with blocks or frames
+func writeFlac(t *testing.T, w io.Writer, format WavFormat, pcm []byte) {
+	t.Helper()
+	fw, err := NewFlacWriter(w, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rest := pcm; len(rest) > 0; {
+		n := min(len(rest), 1001)
+		if _, err := fw.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkStreamInfo(t *testing.T, stream *flac.Stream, format WavFormat) {
+	t.Helper()
+	info := stream.Info
+	if int(info.SampleRate) != format.SampleRate || int(info.NChannels) != format.Channels ||
+		int(info.BitsPerSample) != format.BitDepth {
+		t.Errorf("STREAMINFO has %d Hz, %d channels and %d bits, want %d Hz, %d channels and %d bits",
+			info.SampleRate, info.NChannels, info.BitsPerSample, format.SampleRate, format.Channels, format.BitDepth)
+	}
+}
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// levelMeterInterval is how much audio is measured per meter update
+	levelMeterInterval = 100 * time.Millisecond
+	// levelMeterWidth is the width of the level bar in characters
+	levelMeterWidth = 30
+	// levelMeterFloorDBFS is the level shown as an empty bar
+	levelMeterFloorDBFS = -60
+)
+
+// LevelMeter implements io.Writer and renders a live level meter for a raw
+// PCM stream on a terminal. Each update redraws a single line with the RMS
+// level as a bar, the peak level as a marker, the elapsed time and, when
+// speech is ending, the time left until auto-stop.
+type LevelMeter struct {
+	w             io.Writer
+	decoder       *frameDecoder
+	sampleRate    int
+	intervalSize  int
+	intervalPos   int
+	sumSquare     float64
+	samples       int
+	peak          float64
+	frames        int
+	detector      SpeechDetector
+	waitForSpeech bool
+}
+
+// NewLevelMeter creates a new LevelMeter rendering to w, which should be a terminal
+func NewLevelMeter(w io.Writer, format SampleFormat, channels, sampleRate int) *LevelMeter {
+	return &LevelMeter{
+		w:            w,
+		decoder:      newFrameDecoder(format, channels),
+		sampleRate:   sampleRate,
+		intervalSize: max(int(levelMeterInterval.Seconds()*float64(sampleRate)), 1),
+	}
+}
+
+// SetDetector shows the speech state and auto-stop countdown of the detector.
+// The detector must be written to before the meter.
+func (m *LevelMeter) SetDetector(detector SpeechDetector, waitForSpeech bool) {
+	m.detector = detector
+	m.waitForSpeech = waitForSpeech
+}
+
+// Write implements io.Writer
+func (m *LevelMeter) Write(p []byte) (n int, err error) {
+	m.decoder.decode(p, func(frame []float64) {
+		for _, val := range frame {
+			m.sumSquare += val * val
+			m.peak = max(m.peak, math.Abs(val))
+		}
+		m.samples += len(frame)
+		m.frames++
+		m.intervalPos++
+		if m.intervalPos == m.intervalSize {
+			m.render()
+			m.intervalPos = 0
+			m.sumSquare = 0
+			m.samples = 0
+			m.peak = 0
+		}
+	})
+	return len(p), nil
+}
+
+// render draws the meter line for the current interval
+func (m *LevelMeter) render() {
+	rms := toDBFS(math.Sqrt(m.sumSquare / float64(max(m.samples, 1))))
+	peak := toDBFS(m.peak)
+
+	// Map levels from the floor to full scale onto the bar
+	position := func(level float64) int {
+		if math.IsInf(level, -1) || level <= levelMeterFloorDBFS {
+			return 0
+		}
+		return min(int((level-levelMeterFloorDBFS)/-levelMeterFloorDBFS*levelMeterWidth), levelMeterWidth)
+	}
+	bar := []byte(strings.Repeat("#", position(rms)) + strings.Repeat("-", levelMeterWidth-position(rms)))
+	if p := position(peak); p > 0 {
+		bar[p-1] = '|'
+	}
+
+	elapsed := framesToDuration(m.frames, m.sampleRate)
+	line := fmt.Sprintf("[%s] %6s dBFS  peak %6s  %s",
+		bar, formatLevel(rms), formatLevel(peak), formatElapsed(elapsed))
+	if m.detector != nil {
+		switch remaining, ok := m.detector.SilenceRemaining(); {
+		case ok:
+			line += fmt.Sprintf("  stopping in %.1fs", remaining.Seconds())
+		case m.detector.Speaking():
+			line += "  speech"
+		case m.waitForSpeech:
+			line += "  waiting for speech"
+		}
+	}
+	// Return to the start of the line and clear it before redrawing
+	_, _ = fmt.Fprintf(m.w, "\r\033[K%s", line)
+}
+
+// Close clears the meter line. The underlying writer is not closed.
+func (m *LevelMeter) Close() error {
+	_, err := fmt.Fprint(m.w, "\r\033[K")
+	return err
+}
+
+// formatLevel formats a dBFS level for the meter
+func formatLevel(level float64) string {
+	if level <= levelMeterFloorDBFS {
+		return "-inf"
+	}
+	return fmt.Sprintf("%.1f", level)
+}
+
+// formatElapsed formats a duration as minutes, seconds and tenths
+func formatElapsed(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	seconds := (d % time.Minute).Seconds()
+	return fmt.Sprintf("%02d:%04.1f", minutes, seconds)
+}
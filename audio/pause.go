@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// PauseSwitch pauses and resumes a capture. It is safe for concurrent use.
+type PauseSwitch struct {
+	paused atomic.Bool
+}
+
+// Pause pauses the capture
+func (s *PauseSwitch) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume resumes the capture
+func (s *PauseSwitch) Resume() {
+	s.paused.Store(false)
+}
+
+// Toggle pauses a running capture or resumes a paused one, and reports whether
+// the capture is now paused
+func (s *PauseSwitch) Toggle() bool {
+	for {
+		paused := s.paused.Load()
+		if s.paused.CompareAndSwap(paused, !paused) {
+			return !paused
+		}
+	}
+}
+
+// Paused reports whether the capture is paused
+func (s *PauseSwitch) Paused() bool {
+	return s.paused.Load()
+}
+
+// pauseWriter discards whole frames written while its switch is paused, so
+// that downstream writers only see the active sections
+type pauseWriter struct {
+	w         io.Writer
+	sw        *PauseSwitch
+	frameSize int
+	partial   int // bytes written of the current frame
+	dropping  bool
+	dropped   int // frames
+}
+
+func newPauseWriter(w io.Writer, sw *PauseSwitch, frameSize int) *pauseWriter {
+	return &pauseWriter{w: w, sw: sw, frameSize: frameSize}
+}
+
+// Write implements io.Writer
+func (w *pauseWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	for len(p) > 0 {
+		// The switch is only checked on frame boundaries
+		k := w.frameSize - w.partial
+		if w.partial == 0 {
+			w.dropping = w.sw.Paused()
+			k = max(len(p)-len(p)%w.frameSize, w.frameSize)
+		}
+		k = min(k, len(p))
+		if w.dropping {
+			w.dropped += (w.partial + k) / w.frameSize
+		} else if _, err := w.w.Write(p[:k]); err != nil {
+			return 0, err
+		}
+		w.partial = (w.partial + k) % w.frameSize
+		p = p[k:]
+	}
+	return n, nil
+}
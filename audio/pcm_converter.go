@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+)
+
+// PCMConverter implements io.WriteCloser and converts raw signed
+// little-endian PCM between formats. Channels are mixed down to mono by
+// averaging them, the sample rate is converted with a windowed-sinc
+// resampler and samples are re-encoded at the target bit depth.
+//
+// Close writes the end of the resampled stream. It does not close the
+// underlying writer.
+type PCMConverter struct {
+	w         io.Writer
+	from, to  WavFormat
+	decoder   *frameDecoder
+	resampler *resampler
+	mono      []float64
+	out       []byte
+}
+
+// NewPCMConverter creates a converter writing PCM in the to format to w. The
+// channel count must be kept or reduced to one.
+func NewPCMConverter(w io.Writer, from, to WavFormat) (*PCMConverter, error) {
+	for _, f := range []WavFormat{from, to} {
+		if err := SignedPCM(f.BitDepth).Validate(); err != nil {
+			return nil, err
+		}
+		if f.SampleRate <= 0 || f.Channels <= 0 {
+			return nil, fmt.Errorf("%d Hz with %d channels: %w", f.SampleRate, f.Channels, ErrUnsupportedConversion)
+		}
+	}
+	if to.Channels != from.Channels && to.Channels != 1 {
+		return nil, fmt.Errorf("mixing %d channels to %d: %w", from.Channels, to.Channels, ErrUnsupportedConversion)
+	}
+
+	c := &PCMConverter{
+		w:       w,
+		from:    from,
+		to:      to,
+		decoder: newFrameDecoder(SignedPCM(from.BitDepth), from.Channels),
+		mono:    make([]float64, 1),
+	}
+	if from.SampleRate != to.SampleRate {
+		c.resampler = newResampler(to.Channels, from.SampleRate, to.SampleRate)
+	}
+	return c, nil
+}
+
+// Write implements io.Writer
+func (c *PCMConverter) Write(p []byte) (int, error) {
+	c.decoder.decode(p, func(frame []float64) {
+		if c.to.Channels < c.from.Channels {
+			frame = c.downmix(frame)
+		}
+		if c.resampler != nil {
+			c.resampler.push(frame, c.encode)
+		} else {
+			c.encode(frame)
+		}
+	})
+	if err := c.flushOutput(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer
+func (c *PCMConverter) Close() error {
+	if c.resampler != nil {
+		c.resampler.flush(c.encode)
+	}
+	return c.flushOutput()
+}
+
+// downmix returns the average of the channels of a frame
+func (c *PCMConverter) downmix(frame []float64) []float64 {
+	var sum float64
+	for _, v := range frame {
+		sum += v
+	}
+	c.mono[0] = sum / float64(len(frame))
+	return c.mono
+}
+
+// encode appends a converted frame to the output
+func (c *PCMConverter) encode(frame []float64) {
+	format := SignedPCM(c.to.BitDepth)
+	size := format.BytesPerSample()
+	for _, v := range frame {
+		c.out = append(c.out, make([]byte, size)...)
+		format.Encode(c.out[len(c.out)-size:], v)
+	}
+}
+
+// flushOutput writes the converted frames to the underlying writer
+func (c *PCMConverter) flushOutput() error {
+	if len(c.out) == 0 {
+		return nil
+	}
+	_, err := c.w.Write(c.out)
+	c.out = c.out[:0]
+	return err
+}
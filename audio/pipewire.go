@@ -0,0 +1,200 @@
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// pipewireDefaultRate is the PipeWire default clock rate, used when a node
+// does not report its rate
+const pipewireDefaultRate = 48000
+
+// pwDumpObject is an object in the pw-dump JSON output
+type pwDumpObject struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	Info struct {
+		Props  map[string]json.RawMessage `json:"props"`
+		Params struct {
+			Format     []pwDumpFormat `json:"Format"`
+			EnumFormat []pwDumpFormat `json:"EnumFormat"`
+		} `json:"params"`
+	} `json:"info"`
+	Props    map[string]json.RawMessage `json:"props"`
+	Metadata []struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	} `json:"metadata"`
+}
+
+// pwDumpFormat is a node format parameter. The rate is a number for
+// negotiated formats and may be a range with a default for enumerated ones.
+type pwDumpFormat struct {
+	Rate json.RawMessage `json:"rate"`
+}
+
+// rate returns the sample rate of the format, or 0 if not known
+func (f pwDumpFormat) rate() int {
+	var rate int
+	if err := json.Unmarshal(f.Rate, &rate); err == nil {
+		return rate
+	}
+	var rng struct {
+		Default int `json:"default"`
+	}
+	if err := json.Unmarshal(f.Rate, &rng); err == nil {
+		return rng.Default
+	}
+	return 0
+}
+
+// pwString returns a string property, or "" if missing
+func pwString(props map[string]json.RawMessage, key string) string {
+	var s string
+	_ = json.Unmarshal(props[key], &s)
+	return s
+}
+
+// pwInt returns an integer property, or 0 if missing. Numeric properties
+// are strings in some PipeWire versions.
+func pwInt(props map[string]json.RawMessage, key string) int {
+	var n int
+	if err := json.Unmarshal(props[key], &n); err == nil {
+		return n
+	}
+	n, _ = strconv.Atoi(pwString(props, key))
+	return n
+}
+
+// parsePwDumpDevices parses pw-dump output into audio source and sink nodes
+func parsePwDumpDevices(output []byte) ([]Device, error) {
+	var objects []pwDumpObject
+	if err := json.Unmarshal(output, &objects); err != nil {
+		return nil, fmt.Errorf("parsing pw-dump: %w", ErrOutputParsingFailed)
+	}
+
+	// The default nodes are stored by name in the "default" metadata object
+	var defaultSource, defaultSink string
+	for _, obj := range objects {
+		if obj.Type != "PipeWire:Interface:Metadata" || pwString(obj.Props, "metadata.name") != "default" {
+			continue
+		}
+		for _, entry := range obj.Metadata {
+			var value struct {
+				Name string `json:"name"`
+			}
+			_ = json.Unmarshal(entry.Value, &value)
+			switch entry.Key {
+			case "default.audio.source":
+				defaultSource = value.Name
+			case "default.audio.sink":
+				defaultSink = value.Name
+			}
+		}
+	}
+
+	var devices []Device
+	index := 0
+	for _, obj := range objects {
+		if obj.Type != "PipeWire:Interface:Node" {
+			continue
+		}
+		props := obj.Info.Props
+		mediaClass := pwString(props, "media.class")
+		var mode uint
+		switch {
+		case strings.HasPrefix(mediaClass, "Audio/Source"):
+			mode = DeviceFlagSource
+		case strings.HasPrefix(mediaClass, "Audio/Sink"):
+			mode = DeviceFlagSink
+		case mediaClass == "Audio/Duplex":
+			mode = DeviceFlagSource | DeviceFlagSink
+		default:
+			// Application streams, video and MIDI nodes
+			continue
+		}
+
+		name := pwString(props, "node.name")
+		if name == defaultSource && mode&DeviceFlagSource != 0 {
+			mode |= DeviceFlagCurrentSource
+		}
+		if name == defaultSink && mode&DeviceFlagSink != 0 {
+			mode |= DeviceFlagCurrentSink
+		}
+
+		sampleRate := pwInt(props, "audio.rate")
+		for _, formats := range [][]pwDumpFormat{obj.Info.Params.Format, obj.Info.Params.EnumFormat} {
+			if sampleRate == 0 && len(formats) > 0 {
+				sampleRate = formats[0].rate()
+			}
+		}
+
+		devices = append(devices, Device{
+			Name:        name,
+			Description: pwString(props, "node.description"),
+			ID:          obj.ID,
+			Mode:        mode,
+			SampleRate:  sampleRate,
+			Index:       index,
+		})
+		index++
+	}
+
+	return devices, nil
+}
+
+// newPipeWireDeviceLister creates a DeviceLister for PipeWire nodes by running pw-dump
+func newPipeWireDeviceLister(ctx context.Context) (*DeviceLister, error) {
+	cmd := exec.CommandContext(ctx, "pw-dump")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("pw-dump: %w", ErrCommandExecutionFailed)
+	}
+
+	devices, err := parsePwDumpDevices(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeviceLister{devices: devices}, nil
+}
+
+// pipewireSampleFormats maps bit depths to pw-record sample formats
+var pipewireSampleFormats = map[int]string{
+	8:  "s8",
+	16: "s16",
+	24: "s24",
+	32: "s32",
+}
+
+// newPipeWireRecorder creates a recorder that captures from a PipeWire node with pw-record
+func newPipeWireRecorder(log *slog.Logger, args RecorderArgs) (Recorder, error) {
+	sampleFormat, ok := pipewireSampleFormats[args.BitDepth]
+	if !ok {
+		return nil, fmt.Errorf("pipewire %d-bit samples: %w", args.BitDepth, ErrUnsupportedSampleFormat)
+	}
+	format := WavFormat{
+		SampleRate: args.Device.SampleRate,
+		Channels:   args.Channels,
+		BitDepth:   args.BitDepth,
+	}
+	if format.SampleRate <= 0 {
+		format.SampleRate = pipewireDefaultRate
+	}
+
+	cmdArgs := []string{
+		"--raw",
+		"--target", args.Device.Name,
+		"--rate", strconv.Itoa(format.SampleRate),
+		"--channels", strconv.Itoa(format.Channels),
+		"--format", sampleFormat,
+		// Use "-" to output to stdout
+		"-",
+	}
+	return newCommandRecorder(log, args.Device, format, "pw-record", cmdArgs...), nil
+}
@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"io"
+	"time"
+)
+
+// preRollSlack is kept in addition to the pre-roll to cover the time it
+// takes the detector to confirm that speech has started
+const preRollSlack = 2 * time.Second
+
+// PreRollGate implements io.Writer and holds back a raw PCM stream until it
+// is opened. While closed, only the most recent audio is kept so that a
+// pre-roll from before the opening position can be written once it opens.
+type PreRollGate struct {
+	w          io.Writer
+	frameSize  int
+	sampleRate int
+	preRoll    time.Duration
+	capacity   int
+	buffer     []byte
+	bufferPos  int64 // stream position of buffer[0]
+	openAt     int64 // stream position to open at, or -1
+	open       bool
+	dropped    int64 // bytes discarded before opening
+}
+
+// NewPreRollGate creates a closed gate writing to w, keeping preRoll of audio
+// from before the position it is opened at
+func NewPreRollGate(w io.Writer, frameSize, sampleRate int, preRoll time.Duration) *PreRollGate {
+	capacityFrames := int((preRoll + preRollSlack).Seconds() * float64(sampleRate))
+	return &PreRollGate{
+		w:          w,
+		frameSize:  frameSize,
+		sampleRate: sampleRate,
+		preRoll:    preRoll,
+		capacity:   capacityFrames * frameSize,
+		openAt:     -1,
+	}
+}
+
+// Open opens the gate at the given stream offset, which is typically the
+// offset of a SpeechStart event. The pre-roll before the offset is included.
+// The gate opens on the next Write that reaches the offset.
+func (g *PreRollGate) Open(offset time.Duration) {
+	frames := int64((offset - g.preRoll).Seconds() * float64(g.sampleRate))
+	g.openAt = max(frames, 0) * int64(g.frameSize)
+}
+
+// Opened reports whether the gate has opened
+func (g *PreRollGate) Opened() bool {
+	return g.open
+}
+
+// Dropped returns the duration of audio discarded before the gate opened
+func (g *PreRollGate) Dropped() time.Duration {
+	return framesToDuration(int(g.dropped)/g.frameSize, g.sampleRate)
+}
+
+// Write implements io.Writer
+func (g *PreRollGate) Write(p []byte) (int, error) {
+	if g.open {
+		return g.w.Write(p)
+	}
+
+	g.buffer = append(g.buffer, p...)
+	if g.openAt >= 0 && g.openAt < g.bufferPos+int64(len(g.buffer)) {
+		// Write from the opening position, or as far back as was kept
+		skip := max(g.openAt-g.bufferPos, 0)
+		g.dropped = g.bufferPos + skip
+		if err := g.release(skip); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	// Drop the oldest whole frames once the buffer has grown to twice the
+	// capacity, so that data is not moved on every write
+	if len(g.buffer) > 2*g.capacity {
+		excess := len(g.buffer) - g.capacity
+		excess -= excess % g.frameSize
+		g.buffer = append(g.buffer[:0], g.buffer[excess:]...)
+		g.bufferPos += int64(excess)
+	}
+	return len(p), nil
+}
+
+// Flush opens the gate and writes the audio kept so far. It is used when the
+// stream ends without the gate having been opened.
+func (g *PreRollGate) Flush() error {
+	if g.open {
+		return nil
+	}
+	g.dropped = g.bufferPos
+	return g.release(0)
+}
+
+// release opens the gate and writes the buffered data from skip onwards
+func (g *PreRollGate) release(skip int64) error {
+	data := g.buffer[skip:]
+	g.open = true
+	g.buffer = nil
+	if _, err := g.w.Write(data); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Input containers that are not written by capture
+const (
+	ContainerAIFF     = "aiff"
+	ContainerCAF      = "caf"
+	ContainerAMR      = "amr"
+	ContainerAAC      = "aac"
+	ContainerMP4      = "mp4"
+	ContainerMOV      = "mov"
+	ContainerThreeGP  = "3gp"
+	ContainerWebM     = "webm"
+	ContainerMatroska = "matroska"
+	ContainerAVI      = "avi"
+)
+
+// probeSize is the number of bytes read from the start of a file to detect its format
+const probeSize = 64 << 10
+
+// MediaInfo describes an audio or video file as detected from its contents
+type MediaInfo struct {
+	// Container is the file format, such as wav, ogg, mp4 or webm
+	Container string
+	// Codec is the audio codec, or empty if the header does not name it
+	Codec string
+	// Format holds the sample rate, channel count and bit depth when the
+	// header has them. Fields that are not known are zero.
+	Format WavFormat
+	// Video reports containers that are used for video files
+	Video bool
+}
+
+// ProbeMedia detects the container and, where the header shows it, the codec
+// and format of a file from its first bytes. It returns ErrUnsupportedInput
+// for unknown formats.
+func ProbeMedia(r io.Reader) (MediaInfo, error) {
+	head := make([]byte, probeSize)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return MediaInfo{}, fmt.Errorf("read header: %w", err)
+	}
+	head = head[:n]
+
+	switch {
+	case hasPrefixAt(head, 0, "RIFF") && hasPrefixAt(head, 8, "WAVE"):
+		return probeWav(head), nil
+	case hasPrefixAt(head, 0, "RIFF") && hasPrefixAt(head, 8, "AVI "):
+		return MediaInfo{Container: ContainerAVI, Video: true}, nil
+	case hasPrefixAt(head, 0, "fLaC"):
+		return MediaInfo{Container: ContainerFLAC, Codec: CodecFLAC, Format: parseFlacStreamInfo(head[4:])}, nil
+	case hasPrefixAt(head, 0, "OggS"):
+		return probeOgg(head), nil
+	case hasPrefixAt(head, 0, "FORM") && (hasPrefixAt(head, 8, "AIFF") || hasPrefixAt(head, 8, "AIFC")):
+		return MediaInfo{Container: ContainerAIFF}, nil
+	case hasPrefixAt(head, 0, "caff"):
+		return MediaInfo{Container: ContainerCAF}, nil
+	case hasPrefixAt(head, 0, "#!AMR-WB\n"):
+		return MediaInfo{Container: ContainerAMR, Format: WavFormat{SampleRate: 16000, Channels: 1}}, nil
+	case hasPrefixAt(head, 0, "#!AMR\n"):
+		return MediaInfo{Container: ContainerAMR, Format: WavFormat{SampleRate: 8000, Channels: 1}}, nil
+	case hasPrefixAt(head, 4, "ftyp"):
+		return probeISOBMFF(head), nil
+	case hasPrefixAt(head, 0, "\x1a\x45\xdf\xa3"):
+		// The EBML header names the document type
+		if bytes.Contains(head[:min(len(head), 64)], []byte("webm")) {
+			return MediaInfo{Container: ContainerWebM, Video: true}, nil
+		}
+		return MediaInfo{Container: ContainerMatroska, Video: true}, nil
+	case hasPrefixAt(head, 0, "ID3"):
+		return MediaInfo{Container: ContainerMP3, Codec: CodecMP3}, nil
+	case len(head) >= 2 && head[0] == 0xff && head[1]&0xf6 == 0xf0:
+		// ADTS frames have layer bits 00, which MPEG audio frames do not use
+		return MediaInfo{Container: ContainerAAC}, nil
+	case len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0:
+		return MediaInfo{Container: ContainerMP3, Codec: CodecMP3}, nil
+	}
+	return MediaInfo{}, ErrUnsupportedInput
+}
+
+// hasPrefixAt reports whether b holds prefix at offset
+func hasPrefixAt(b []byte, offset int, prefix string) bool {
+	return len(b) >= offset+len(prefix) && string(b[offset:offset+len(prefix)]) == prefix
+}
+
+// probeWav reads the format of a WAV header. Headers that are truncated or
+// use a compressed format leave the codec and format unknown.
+func probeWav(head []byte) MediaInfo {
+	info := MediaInfo{Container: ContainerWAV}
+	if wr, err := NewWavReader(bytes.NewReader(head)); err == nil {
+		info.Codec = CodecPCM
+		info.Format = wr.Format
+	}
+	return info
+}
+
+// parseFlacStreamInfo reads the format from the metadata block that follows
+// the fLaC marker, which always starts with STREAMINFO
+func parseFlacStreamInfo(b []byte) WavFormat {
+	// A 4 byte block header, then 10 bytes of block and frame sizes
+	if len(b) < 4+flacStreamInfoSize || b[0]&0x7f != 0 {
+		return WavFormat{}
+	}
+	b = b[14:]
+	return WavFormat{
+		SampleRate: int(b[0])<<12 | int(b[1])<<4 | int(b[2])>>4,
+		Channels:   int(b[2]>>1&0x07) + 1,
+		BitDepth:   int(b[2]&0x01)<<4 | int(b[3])>>4 + 1,
+	}
+}
+
+// probeOgg reads the codec from the first packet of an Ogg stream
+func probeOgg(head []byte) MediaInfo {
+	info := MediaInfo{Container: ContainerOgg}
+	if len(head) < 27 || len(head) < 27+int(head[26]) {
+		return info
+	}
+	packet := head[27+int(head[26]):]
+	le := binary.LittleEndian
+	switch {
+	case hasPrefixAt(packet, 0, "OpusHead") && len(packet) >= 19:
+		// Opus always decodes at 48 kHz
+		info.Codec = CodecOpus
+		info.Format = WavFormat{SampleRate: 48000, Channels: int(packet[9])}
+	case hasPrefixAt(packet, 0, "\x01vorbis") && len(packet) >= 16:
+		info.Codec = CodecVorbis
+		info.Format = WavFormat{SampleRate: int(le.Uint32(packet[12:16])), Channels: int(packet[11])}
+	case hasPrefixAt(packet, 0, "\x7fFLAC") && hasPrefixAt(packet, 9, "fLaC"):
+		info.Codec = CodecFLAC
+		info.Format = parseFlacStreamInfo(packet[13:])
+	}
+	return info
+}
+
+// probeISOBMFF tells MP4, QuickTime and 3GPP files apart by their major brand
+func probeISOBMFF(head []byte) MediaInfo {
+	if len(head) < 12 {
+		return MediaInfo{Container: ContainerMP4, Video: true}
+	}
+	switch brand := string(head[8:12]); {
+	case brand == "M4A " || brand == "M4B ":
+		return MediaInfo{Container: ContainerMP4}
+	case brand == "qt  ":
+		return MediaInfo{Container: ContainerMOV, Video: true}
+	case brand[:3] == "3gp" || brand[:3] == "3g2":
+		return MediaInfo{Container: ContainerThreeGP, Video: true}
+	default:
+		return MediaInfo{Container: ContainerMP4, Video: true}
+	}
+}
@@ -0,0 +1,150 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// qualityBlock is the block size used for noise and signal level estimation
+const qualityBlock = 20 * time.Millisecond
+
+// QualityReport holds level and quality measurements for a PCM stream.
+// Levels are in dBFS, where 0 dBFS is full scale.
+type QualityReport struct {
+	PeakDBFS float64
+	RMSDBFS  float64
+	// ClippingPercent is the percentage of samples at full scale
+	ClippingPercent float64
+	// DCOffset is the mean sample value relative to full scale
+	DCOffset float64
+	// SNRDB is the estimated signal-to-noise ratio, or NaN if too little audio was analyzed
+	SNRDB float64
+}
+
+// QualityThresholds holds the limits used to judge a QualityReport
+type QualityThresholds struct {
+	MinPeakDBFS        float64
+	MaxClippingPercent float64
+	MaxDCOffset        float64
+	MinSNRDB           float64
+}
+
+// Problems returns a description of each threshold the report violates
+func (r QualityReport) Problems(t QualityThresholds) []string {
+	var problems []string
+	if math.IsInf(r.PeakDBFS, -1) {
+		return []string{"no signal (digital silence), check that the right device is selected"}
+	}
+	if r.PeakDBFS < t.MinPeakDBFS {
+		problems = append(problems, fmt.Sprintf(
+			"input level is very low (peak %.1f dBFS < %.1f dBFS), check the device selection and gain",
+			r.PeakDBFS, t.MinPeakDBFS))
+	}
+	if r.ClippingPercent > t.MaxClippingPercent {
+		problems = append(problems, fmt.Sprintf(
+			"input is clipping (%.2f%% of samples at full scale > %.2f%%), lower the gain",
+			r.ClippingPercent, t.MaxClippingPercent))
+	}
+	if math.Abs(r.DCOffset) > t.MaxDCOffset {
+		problems = append(problems, fmt.Sprintf(
+			"large DC offset (%.3f > %.3f), the device may be faulty",
+			r.DCOffset, t.MaxDCOffset))
+	}
+	if !math.IsNaN(r.SNRDB) && r.SNRDB < t.MinSNRDB {
+		problems = append(problems, fmt.Sprintf(
+			"low signal-to-noise ratio (%.1f dB < %.1f dB), reduce background noise or move closer",
+			r.SNRDB, t.MinSNRDB))
+	}
+	return problems
+}
+
+// QualityAnalyzer implements io.Writer and measures levels and quality of a raw PCM stream
+type QualityAnalyzer struct {
+	decoder   *frameDecoder
+	channels  int
+	clipLevel float64
+	samples   int
+	clipped   int
+	peak      float64
+	sum       float64
+	sumSquare float64
+
+	blockFrames    int
+	blockPos       int
+	blockSumSquare float64
+	blockRMS       []float64
+}
+
+// NewQualityAnalyzer creates a new QualityAnalyzer
+func NewQualityAnalyzer(channels int, format SampleFormat, sampleRate int) *QualityAnalyzer {
+	return &QualityAnalyzer{
+		decoder:     newFrameDecoder(format, channels),
+		channels:    channels,
+		clipLevel:   format.ClipLevel(),
+		blockFrames: windowFrames(qualityBlock, sampleRate),
+	}
+}
+
+// Write implements io.Writer
+func (a *QualityAnalyzer) Write(p []byte) (n int, err error) {
+	a.decoder.decode(p, func(frame []float64) {
+		for _, val := range frame {
+			abs := math.Abs(val)
+			if abs >= a.clipLevel {
+				a.clipped++
+			}
+			a.peak = max(a.peak, abs)
+			a.sum += val
+			a.sumSquare += val * val
+			a.blockSumSquare += val * val
+			a.samples++
+		}
+		a.blockPos++
+		if a.blockPos == a.blockFrames {
+			a.blockRMS = append(a.blockRMS, math.Sqrt(a.blockSumSquare/float64(a.blockPos*a.channels)))
+			a.blockPos = 0
+			a.blockSumSquare = 0
+		}
+	})
+
+	return len(p), nil
+}
+
+// Report returns the measurements for the audio seen so far
+func (a *QualityAnalyzer) Report() QualityReport {
+	r := QualityReport{
+		PeakDBFS: math.Inf(-1),
+		RMSDBFS:  math.Inf(-1),
+		SNRDB:    math.NaN(),
+	}
+	if a.samples == 0 {
+		return r
+	}
+	r.PeakDBFS = toDBFS(a.peak)
+	r.RMSDBFS = toDBFS(math.Sqrt(a.sumSquare / float64(a.samples)))
+	r.ClippingPercent = 100 * float64(a.clipped) / float64(a.samples)
+	r.DCOffset = a.sum / float64(a.samples)
+
+	// Estimate noise and signal levels from the quietest and loudest blocks
+	const minBlocks = 10
+	if len(a.blockRMS) >= minBlocks {
+		sorted := slices.Clone(a.blockRMS)
+		slices.Sort(sorted)
+		noise := sorted[len(sorted)/10]
+		signal := sorted[len(sorted)*9/10]
+		if noise > 0 {
+			r.SNRDB = 20 * math.Log10(signal/noise)
+		}
+	}
+	return r
+}
+
+// toDBFS converts a linear amplitude relative to full scale to dBFS
+func toDBFS(v float64) float64 {
+	if v <= 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(v)
+}
@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+)
+
+// Recorder backends
+const (
+	// BackendAuto selects sox, or ffmpeg when sox is not installed. Linux
+	// machines without PulseAudio use ALSA.
+	BackendAuto     = "auto"
+	BackendSox      = "sox"
+	BackendFFmpeg   = "ffmpeg"
+	BackendPipeWire = "pipewire"
+	BackendALSA     = "alsa"
+)
+
+// Recorder records raw PCM from an audio device.
+//
+// Recording starts with Start and ends when Stop is called or the context
+// passed to Start is done. Audio recorded before the end can still be read,
+// after which Read returns io.EOF.
+type Recorder interface {
+	io.Reader
+	// Start starts recording
+	Start(ctx context.Context) error
+	// Stop stops recording. It is safe to call more than once.
+	Stop() error
+	// Format returns the format of the recorded PCM, which is signed and
+	// little-endian at any bit depth
+	Format() WavFormat
+}
+
+// RecorderArgs holds the arguments for NewRecorder
+type RecorderArgs struct {
+	// Backend is the recorder backend, defaults to BackendAuto
+	Backend  string
+	Device   Device
+	Channels int
+	BitDepth int
+}
+
+// NewRecorder creates a recorder for the selected backend. File, stdin and
+// synthetic source devices are read directly with any backend.
+func NewRecorder(logger *slog.Logger, args RecorderArgs) (Recorder, error) {
+	if _, ok := SourceDevice(args.Device.Name); ok {
+		return newSourceRecorder(args)
+	}
+	switch resolveBackend(args.Backend) {
+	case BackendSox:
+		return newSoxRecorder(logger, args), nil
+	case BackendFFmpeg:
+		return newFFmpegRecorder(logger, args)
+	case BackendPipeWire:
+		return newPipeWireRecorder(logger, args)
+	case BackendALSA:
+		return newALSARecorder(logger, args)
+	default:
+		return nil, fmt.Errorf("backend %q: %w", args.Backend, ErrUnknownBackend)
+	}
+}
+
+// NewBackendDeviceLister creates a DeviceLister for the devices of a backend.
+// Backends that record through the platform audio system share its devices.
+func NewBackendDeviceLister(ctx context.Context, backend string) (*DeviceLister, error) {
+	switch resolveBackend(backend) {
+	case BackendPipeWire:
+		return newPipeWireDeviceLister(ctx)
+	case BackendALSA:
+		return newALSADeviceLister(ctx)
+	default:
+		return NewDeviceLister(ctx)
+	}
+}
+
+// resolveBackend returns the backend used for BackendAuto on this machine
+func resolveBackend(backend string) string {
+	if backend == BackendAuto || backend == "" {
+		return defaultBackend()
+	}
+	return backend
+}
+
+// commandBackend returns the installed tool for recording through the
+// platform audio system and converting audio, preferring sox over ffmpeg
+func commandBackend() string {
+	if _, err := exec.LookPath("sox"); err != nil {
+		if _, err := exec.LookPath("ffmpeg"); err == nil {
+			return BackendFFmpeg
+		}
+	}
+	return BackendSox
+}
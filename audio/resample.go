@@ -0,0 +1,165 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	// resampleZeroCrossings is the number of sinc zero crossings on each side
+	// of the filter, which sets the steepness of its cutoff
+	resampleZeroCrossings = 16
+	// resampleRolloff places the cutoff just below the Nyquist frequency of
+	// the lower rate, so that the transition band does not alias
+	resampleRolloff = 0.95
+	// resampleKaiserBeta is the Kaiser window shape, giving about 80 dB of
+	// stopband attenuation
+	resampleKaiserBeta = 8
+	// resampleMaxPhases is the largest number of filter phases kept in memory.
+	// Rates with a larger ratio compute the filter for each output frame.
+	resampleMaxPhases = 4096
+)
+
+// resampler converts the sample rate of interleaved float frames with a
+// polyphase windowed-sinc filter. Input before the first frame and after the
+// last is treated as silence.
+type resampler struct {
+	channels int
+	// up and down are the output to input rate ratio in lowest terms
+	up, down int
+	// half is the filter half length in input frames
+	half   int
+	cutoff float64
+	// phases holds the normalized filter taps for each output phase, or nil
+	// when they are computed as needed
+	phases [][]float64
+	taps   []float64
+	// buf holds input frames from index base onwards
+	buf  []float64
+	base int64
+	// out is the index of the next output frame
+	out   int64
+	frame []float64
+}
+
+func newResampler(channels, fromRate, toRate int) *resampler {
+	g := gcd(fromRate, toRate)
+	r := &resampler{
+		channels: channels,
+		up:       toRate / g,
+		down:     fromRate / g,
+		frame:    make([]float64, channels),
+	}
+	// The cutoff is relative to the input rate, at the Nyquist frequency of
+	// the lower of the two rates
+	r.cutoff = 0.5 * resampleRolloff * min(1, float64(r.up)/float64(r.down))
+	r.half = int(math.Ceil(resampleZeroCrossings / (2 * r.cutoff)))
+	r.taps = make([]float64, 2*r.half)
+	if r.up <= resampleMaxPhases {
+		r.phases = make([][]float64, r.up)
+		for p := range r.phases {
+			r.phases[p] = r.filter(p, make([]float64, 2*r.half))
+		}
+	}
+
+	// Start with silence before the first frame
+	r.buf = make([]float64, (r.half-1)*channels)
+	r.base = -int64(r.half - 1)
+	return r
+}
+
+// filter computes the taps for output phase p into taps, normalized to unit gain
+func (r *resampler) filter(p int, taps []float64) []float64 {
+	offset := float64(p) / float64(r.up)
+	var sum float64
+	for k := range taps {
+		// Distance in input frames from the output frame to input frame k
+		x := float64(k-r.half+1) - offset
+		rel := x / float64(r.half)
+		w := besselI0(resampleKaiserBeta*math.Sqrt(max(0, 1-rel*rel))) / besselI0(resampleKaiserBeta)
+		taps[k] = 2 * r.cutoff * sinc(2*r.cutoff*x) * w
+		sum += taps[k]
+	}
+	for k := range taps {
+		taps[k] /= sum
+	}
+	return taps
+}
+
+// push adds an input frame and calls emit for each output frame that can be
+// computed. The emitted slice is reused between calls.
+func (r *resampler) push(frame []float64, emit func([]float64)) {
+	r.buf = append(r.buf, frame...)
+	r.drain(r.base+int64(len(r.buf)/r.channels), emit)
+}
+
+// flush emits the remaining output frames, ending the input with silence.
+// The output then covers the same duration as the input.
+func (r *resampler) flush(emit func([]float64)) {
+	r.buf = append(r.buf, make([]float64, r.half*r.channels)...)
+	r.drain(r.base+int64(len(r.buf)/r.channels), emit)
+}
+
+// drain emits the output frames whose filter only uses input frames before
+// the end index. Output frames past the last input frame are never emitted,
+// since they would need the silence padding beyond it.
+func (r *resampler) drain(end int64, emit func([]float64)) {
+	for {
+		pos := r.out * int64(r.down)
+		i := pos / int64(r.up)
+		if i+int64(r.half) >= end {
+			break
+		}
+		phase := int(pos % int64(r.up))
+		taps := r.taps
+		if r.phases != nil {
+			taps = r.phases[phase]
+		} else {
+			r.filter(phase, taps)
+		}
+
+		clear(r.frame)
+		start := int(i-int64(r.half)+1-r.base) * r.channels
+		for k, tap := range taps {
+			offset := start + k*r.channels
+			for ch := range r.frame {
+				r.frame[ch] += tap * r.buf[offset+ch]
+			}
+		}
+		emit(r.frame)
+		r.out++
+	}
+
+	// Drop input frames that no later output frame uses
+	next := r.out * int64(r.down) / int64(r.up)
+	if drop := next - int64(r.half) + 1 - r.base; drop > 0 {
+		n := int(drop) * r.channels
+		r.buf = append(r.buf[:0], r.buf[n:]...)
+		r.base += drop
+	}
+}
+
+// sinc is the normalized sinc function
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
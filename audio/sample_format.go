@@ -0,0 +1,209 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// SampleEncoding is the numeric encoding of PCM samples
+type SampleEncoding int
+
+const (
+	// SignedInt is two's complement integer samples
+	SignedInt SampleEncoding = iota
+	// UnsignedInt is offset binary integer samples, where silence is half of full scale
+	UnsignedInt
+	// Float is IEEE 754 floating point samples in the range [-1, 1]
+	Float
+)
+
+// String returns the sox name of the encoding
+func (e SampleEncoding) String() string {
+	switch e {
+	case SignedInt:
+		return "signed-integer"
+	case UnsignedInt:
+		return "unsigned-integer"
+	case Float:
+		return "floating-point"
+	default:
+		return fmt.Sprintf("SampleEncoding(%d)", int(e))
+	}
+}
+
+// SampleFormat describes how a single PCM sample is stored
+type SampleFormat struct {
+	Encoding  SampleEncoding
+	BitDepth  int
+	BigEndian bool
+}
+
+// SignedPCM returns the little-endian signed integer format produced by capture
+func SignedPCM(bitDepth int) SampleFormat {
+	return SampleFormat{Encoding: SignedInt, BitDepth: bitDepth}
+}
+
+// SampleFormat returns the sample format of the WAV stream. 8-bit WAV is
+// unsigned, all other integer depths are signed.
+func (f WavFormat) SampleFormat() SampleFormat {
+	switch {
+	case f.Float:
+		return SampleFormat{Encoding: Float, BitDepth: f.BitDepth}
+	case f.BitDepth == 8:
+		return SampleFormat{Encoding: UnsignedInt, BitDepth: 8}
+	default:
+		return SignedPCM(f.BitDepth)
+	}
+}
+
+// Validate checks that the format can be decoded
+func (f SampleFormat) Validate() error {
+	switch f.Encoding {
+	case SignedInt, UnsignedInt:
+		switch f.BitDepth {
+		case 8, 16, 24, 32:
+			return nil
+		}
+	case Float:
+		if f.BitDepth == 32 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%d-bit %s: %w", f.BitDepth, f.Encoding, ErrUnsupportedSampleFormat)
+}
+
+// BytesPerSample returns the size of a single sample in bytes
+func (f SampleFormat) BytesPerSample() int {
+	return f.BitDepth / 8
+}
+
+// ClipLevel returns the normalized amplitude at which a sample is considered clipped
+func (f SampleFormat) ClipLevel() float64 {
+	if f.Encoding == Float {
+		return 1
+	}
+	return 1 - 1/float64(uint64(1)<<uint(f.BitDepth-1))
+}
+
+// Decode converts a single sample to a float in the range [-1, 1)
+func (f SampleFormat) Decode(b []byte) float64 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if f.BigEndian {
+		order = binary.BigEndian
+	}
+
+	if f.Encoding == Float {
+		return float64(math.Float32frombits(order.Uint32(b)))
+	}
+
+	var u uint32
+	switch f.BitDepth {
+	case 8:
+		u = uint32(b[0])
+	case 16:
+		u = uint32(order.Uint16(b))
+	case 24:
+		if f.BigEndian {
+			u = uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		} else {
+			u = uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+		}
+	case 32:
+		u = order.Uint32(b)
+	default:
+		return 0
+	}
+
+	// Move the sample to the top of a 32-bit word so that the sign bit is in
+	// place regardless of bit depth. Unsigned samples are converted by
+	// flipping the top bit.
+	u <<= uint(32 - f.BitDepth)
+	if f.Encoding == UnsignedInt {
+		u ^= 1 << 31
+	}
+	return float64(int32(u)) / (1 << 31)
+}
+
+// Encode stores a float in the range [-1, 1] as a single sample, clipping
+// values outside the range. It is the inverse of Decode.
+func (f SampleFormat) Encode(b []byte, v float64) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if f.BigEndian {
+		order = binary.BigEndian
+	}
+
+	v = max(-1, min(v, 1))
+	if f.Encoding == Float {
+		order.PutUint32(b, math.Float32bits(float32(v)))
+		return
+	}
+
+	// Round to the bit depth and move the sample to the top of a 32-bit word,
+	// mirroring Decode
+	scale := float64(uint64(1) << uint(f.BitDepth-1))
+	u := uint32(int64(max(-scale, min(math.Round(v*scale), scale-1)))) << uint(32-f.BitDepth)
+	if f.Encoding == UnsignedInt {
+		u ^= 1 << 31
+	}
+	u >>= uint(32 - f.BitDepth)
+	switch f.BitDepth {
+	case 8:
+		b[0] = byte(u)
+	case 16:
+		order.PutUint16(b, uint16(u))
+	case 24:
+		if f.BigEndian {
+			b[0], b[1], b[2] = byte(u>>16), byte(u>>8), byte(u)
+		} else {
+			b[0], b[1], b[2] = byte(u), byte(u>>8), byte(u>>16)
+		}
+	case 32:
+		order.PutUint32(b, u)
+	}
+}
+
+// frameDecoder decodes interleaved PCM into frames of normalized samples,
+// carrying partial frames over between calls
+type frameDecoder struct {
+	format   SampleFormat
+	channels int
+	pending  []byte
+	frame    []float64
+}
+
+func newFrameDecoder(format SampleFormat, channels int) *frameDecoder {
+	return &frameDecoder{
+		format:   format,
+		channels: channels,
+		frame:    make([]float64, channels),
+	}
+}
+
+// decode calls fn for each complete frame in p. The frame slice is reused
+// between calls.
+func (d *frameDecoder) decode(p []byte, fn func(frame []float64)) {
+	data := p
+	if len(d.pending) > 0 {
+		data = append(d.pending, p...)
+		d.pending = nil
+	}
+
+	bytesPerSample := d.format.BytesPerSample()
+	frameSize := bytesPerSample * d.channels
+	if frameSize <= 0 {
+		return
+	}
+	frames := len(data) / frameSize
+	for i := 0; i < frames; i++ {
+		offset := i * frameSize
+		for ch := range d.frame {
+			sampleOffset := offset + ch*bytesPerSample
+			d.frame[ch] = d.format.Decode(data[sampleOffset : sampleOffset+bytesPerSample])
+		}
+		fn(d.frame)
+	}
+	if rest := data[frames*frameSize:]; len(rest) > 0 {
+		d.pending = append([]byte(nil), rest...)
+	}
+}
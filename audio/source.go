@@ -0,0 +1,341 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source device names, which record from a file, stdin or a generator
+// instead of an audio device
+const (
+	// SourceFilePrefix replays a WAV file at real-time pace, as in "file:sample.wav"
+	SourceFilePrefix = "file:"
+	// SourceStdin reads raw signed little-endian PCM from stdin. "-" is an alias.
+	SourceStdin = "stdin"
+	// SourceSynthPrefix generates a pattern of tones, noise and silence at
+	// real-time pace, as in "synth:tone/2s,silence/3s"
+	SourceSynthPrefix = "synth:"
+)
+
+const (
+	// sourceDefaultRate is used for stdin and synthetic sources without a rate
+	sourceDefaultRate = 48000
+	// sourceChunk is the duration of audio released at a time by paced sources
+	sourceChunk = 20 * time.Millisecond
+	// synthToneFrequency is the default tone frequency in Hz
+	synthToneFrequency = 440
+	// synthToneLevel is the peak level of tones in dBFS
+	synthToneLevel = -12
+	// synthNoiseLevel is the default RMS level of noise in dBFS
+	synthNoiseLevel = -50
+)
+
+// SourceDevice returns the device for a file, stdin or synthetic source
+// name, and whether the name is one
+func SourceDevice(name string) (Device, bool) {
+	var description string
+	switch {
+	case strings.HasPrefix(name, SourceFilePrefix):
+		description = "WAV file " + strings.TrimPrefix(name, SourceFilePrefix)
+	case IsStdinSource(name):
+		description = "Raw PCM from stdin"
+	case strings.HasPrefix(name, SourceSynthPrefix):
+		description = "Synthetic " + strings.TrimPrefix(name, SourceSynthPrefix)
+	default:
+		return Device{}, false
+	}
+	return Device{
+		Name:        name,
+		Description: description,
+		Mode:        DeviceFlagSource | DeviceFlagCurrentSource,
+	}, true
+}
+
+// IsStdinSource reports whether a device name reads audio from stdin
+func IsStdinSource(name string) bool {
+	return name == SourceStdin || name == "-"
+}
+
+// sourceRecorder implements Recorder for file, stdin and synthetic sources.
+// The source is read in a goroutine so that Stop can end a blocked Read.
+type sourceRecorder struct {
+	name     string
+	format   WavFormat
+	src      io.Reader
+	closer   io.Closer
+	pace     bool
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newSourceRecorder creates a recorder for a source device name. Stdin and
+// synthetic sources use the device rate, while files keep their own rate and
+// channels. Samples are converted to the requested bit depth.
+func newSourceRecorder(args RecorderArgs) (Recorder, error) {
+	sampleFormat := SignedPCM(args.BitDepth)
+	if err := sampleFormat.Validate(); err != nil {
+		return nil, err
+	}
+	format := WavFormat{
+		SampleRate: args.Device.SampleRate,
+		Channels:   args.Channels,
+		BitDepth:   args.BitDepth,
+	}
+	if format.SampleRate <= 0 {
+		format.SampleRate = sourceDefaultRate
+	}
+
+	r := &sourceRecorder{
+		name: args.Device.Name,
+		done: make(chan struct{}),
+	}
+	r.pr, r.pw = io.Pipe()
+
+	name := args.Device.Name
+	switch {
+	case strings.HasPrefix(name, SourceFilePrefix):
+		path := strings.TrimPrefix(name, SourceFilePrefix)
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", err, ErrInvalidSource)
+		}
+		wav, err := NewWavReader(f)
+		if err == nil {
+			err = wav.Format.SampleFormat().Validate()
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("read %s: %w: %w", path, err, ErrInvalidSource)
+		}
+		format.SampleRate = wav.Format.SampleRate
+		format.Channels = wav.Format.Channels
+		r.src = &sampleConverter{r: wav, from: wav.Format.SampleFormat(), to: sampleFormat}
+		r.closer = f
+		r.pace = true
+	case IsStdinSource(name):
+		r.src = os.Stdin
+	case strings.HasPrefix(name, SourceSynthPrefix):
+		steps, err := parseSynthPattern(strings.TrimPrefix(name, SourceSynthPrefix))
+		if err != nil {
+			return nil, err
+		}
+		r.src = &synthReader{
+			format:   sampleFormat,
+			channels: format.Channels,
+			rate:     format.SampleRate,
+			steps:    steps,
+			// A fixed seed keeps generated noise the same between runs
+			rng: rand.New(rand.NewPCG(1, 2)),
+		}
+		r.pace = true
+	default:
+		return nil, fmt.Errorf("source %q: %w", name, ErrInvalidSource)
+	}
+	r.format = format
+	return r, nil
+}
+
+// Start implements Recorder
+func (r *sourceRecorder) Start(ctx context.Context) error {
+	context.AfterFunc(ctx, func() { _ = r.Stop() })
+	go r.run()
+	return nil
+}
+
+// run copies whole frames from the source to the pipe, releasing each chunk
+// once it would have been recorded by a live device when pacing
+func (r *sourceRecorder) run() {
+	frameSize := r.format.blockAlign()
+	bytesPerSecond := float64(frameSize * r.format.SampleRate)
+	chunk := make([]byte, frameSize*max(1, int(float64(r.format.SampleRate)*sourceChunk.Seconds())))
+	start := time.Now()
+	var total int
+	for {
+		n, err := io.ReadFull(r.src, chunk)
+		n -= n % frameSize
+		if n > 0 {
+			total += n
+			if r.pace {
+				due := start.Add(time.Duration(float64(total) / bytesPerSecond * float64(time.Second)))
+				select {
+				case <-time.After(time.Until(due)):
+				case <-r.done:
+					return
+				}
+			}
+			if _, err := r.pw.Write(chunk[:n]); err != nil {
+				return
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			_ = r.pw.Close()
+			return
+		}
+		if err != nil {
+			_ = r.pw.CloseWithError(fmt.Errorf("read %s: %w: %w", r.name, err, ErrAudioCaptureFailed))
+			return
+		}
+	}
+}
+
+// Read implements Recorder
+func (r *sourceRecorder) Read(p []byte) (int, error) {
+	return r.pr.Read(p)
+}
+
+// Stop implements Recorder. A read from stdin cannot be interrupted, so it
+// is left blocked and its data is discarded.
+func (r *sourceRecorder) Stop() error {
+	r.stopOnce.Do(func() {
+		close(r.done)
+		_ = r.pw.Close()
+		if r.closer != nil {
+			_ = r.closer.Close()
+		}
+	})
+	return nil
+}
+
+// Format implements Recorder
+func (r *sourceRecorder) Format() WavFormat {
+	return r.format
+}
+
+// sampleConverter converts samples read from r between sample formats
+type sampleConverter struct {
+	r        io.Reader
+	from, to SampleFormat
+	buf      []byte
+}
+
+// Read implements io.Reader
+func (c *sampleConverter) Read(p []byte) (int, error) {
+	if c.from == c.to {
+		return c.r.Read(p)
+	}
+	inSize, outSize := c.from.BytesPerSample(), c.to.BytesPerSample()
+	samples := len(p) / outSize
+	if samples == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	if cap(c.buf) < samples*inSize {
+		c.buf = make([]byte, samples*inSize)
+	}
+	n, err := io.ReadFull(c.r, c.buf[:samples*inSize])
+	for i := 0; i < n/inSize; i++ {
+		c.to.Encode(p[i*outSize:], c.from.Decode(c.buf[i*inSize:]))
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n / inSize * outSize, err
+}
+
+// synthStep is a step of a synthetic source pattern
+type synthStep struct {
+	kind string
+	// value is the tone frequency in Hz or the noise level in dBFS
+	value float64
+	// duration is the length of the step, or 0 for no end
+	duration time.Duration
+}
+
+// parseSynthPattern parses a comma-separated list of KIND[=VALUE][/DURATION]
+// steps, where KIND is tone, noise or silence. The value of a tone is its
+// frequency in Hz and that of noise its RMS level in dBFS. A step without a
+// duration never ends.
+func parseSynthPattern(pattern string) ([]synthStep, error) {
+	specs := strings.Split(pattern, ",")
+	steps := make([]synthStep, 0, len(specs))
+	for i, spec := range specs {
+		var step synthStep
+		spec, duration, hasDuration := strings.Cut(strings.TrimSpace(spec), "/")
+		if hasDuration {
+			d, err := time.ParseDuration(duration)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("synth step %q duration: %w", spec, ErrInvalidSource)
+			}
+			step.duration = d
+		} else if i < len(specs)-1 {
+			return nil, fmt.Errorf("synth step %q needs a duration, only the last step may be endless: %w",
+				spec, ErrInvalidSource)
+		}
+		kind, value, hasValue := strings.Cut(spec, "=")
+		step.kind = kind
+		switch kind {
+		case "tone":
+			step.value = synthToneFrequency
+		case "noise":
+			step.value = synthNoiseLevel
+		case "silence":
+			if hasValue {
+				return nil, fmt.Errorf("synth step %q takes no value: %w", spec, ErrInvalidSource)
+			}
+		default:
+			return nil, fmt.Errorf("synth step %q (valid kinds: tone, noise, silence): %w", spec, ErrInvalidSource)
+		}
+		if hasValue {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || (kind == "tone" && v <= 0) {
+				return nil, fmt.Errorf("synth step %q value: %w", spec, ErrInvalidSource)
+			}
+			step.value = v
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// synthReader generates PCM for a synthetic source pattern
+type synthReader struct {
+	format   SampleFormat
+	channels int
+	rate     int
+	steps    []synthStep
+	rng      *rand.Rand
+	// step is the current step and frame the frames generated for it
+	step  int
+	frame int
+}
+
+// Read implements io.Reader
+func (s *synthReader) Read(p []byte) (int, error) {
+	sampleSize := s.format.BytesPerSample()
+	frameSize := sampleSize * s.channels
+	n := 0
+	for ; n+frameSize <= len(p); n += frameSize {
+		if s.step >= len(s.steps) {
+			return n, io.EOF
+		}
+		step := s.steps[s.step]
+		var v float64
+		switch step.kind {
+		case "tone":
+			t := float64(s.frame) / float64(s.rate)
+			v = math.Pow(10, synthToneLevel/20.0) * math.Sin(2*math.Pi*step.value*t)
+		case "noise":
+			v = math.Pow(10, step.value/20) * s.rng.NormFloat64()
+		}
+		for ch := 0; ch < s.channels; ch++ {
+			s.format.Encode(p[n+ch*sampleSize:], v)
+		}
+
+		s.frame++
+		if step.duration > 0 && s.frame >= int(step.duration.Seconds()*float64(s.rate)) {
+			s.step++
+			s.frame = 0
+		}
+	}
+	return n, nil
+}
@@ -1,26 +1,222 @@
 package audio
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"time"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
-// CaptureArgs holds the arguments for audio capture
-type CaptureArgs struct {
-	Device   Device
-	Duration time.Duration
-	Output   io.Writer
-	Channels int
-	BitDepth int
-}
-
 // ConvertAudioArgs holds the arguments for audio conversion
 type ConvertAudioArgs struct {
-	Reader       io.Reader
-	Writer       io.Writer
+	Reader io.Reader
+	Writer io.Writer
+	// SourcePath is a file read instead of Reader, which lets the conversion
+	// tool seek in containers that keep their index at the end
+	SourcePath   string
 	SourceFormat string
 	TargetFormat string
 	SampleRate   int
 	Channels     int
 	BitDepth     int
+	// Codec is the codec in the target container, defaults to the container default
+	Codec string
+	// Bitrate is the bitrate of lossy codecs in kbit/s, 0 for the encoder default
+	Bitrate int
+	// Quality is the quality of lossy codecs from 1 (smallest) to 10 (best),
+	// 0 for the encoder default. For Opus it selects the encoder complexity.
+	Quality int
+	// Backend is the conversion tool, BackendSox or BackendFFmpeg. Other
+	// values select whichever is installed, preferring sox. Codecs that sox
+	// cannot encode always use ffmpeg.
+	Backend string
+}
+
+// ConvertAudio converts audio from sourceFormat to targetFormat using sox or ffmpeg
+func ConvertAudio(ctx context.Context, log *slog.Logger, args ConvertAudioArgs) (retErr error) {
+	// Known containers must be able to hold the codec. Other formats are
+	// passed to the conversion tool as is.
+	if _, ok := containerCodecs[args.TargetFormat]; ok {
+		codec, err := ResolveCodec(args.TargetFormat, args.Codec)
+		if err != nil {
+			return err
+		}
+		args.Codec = codec
+	}
+
+	// Conversions that don't need sox, such as raw PCM to WAV, run in-process
+	if ok, err := convertNative(args); ok {
+		log.DebugContext(ctx, "Converted audio natively",
+			"source_format", args.SourceFormat,
+			"target_format", args.TargetFormat)
+		return err
+	}
+
+	backend := args.Backend
+	if backend != BackendSox && backend != BackendFFmpeg {
+		backend = commandBackend()
+	}
+	if backend == BackendSox && !soxCanEncode(args) {
+		log.DebugContext(ctx, "Converting with ffmpeg, sox cannot encode the target",
+			"target_format", args.TargetFormat,
+			"codec", args.Codec)
+		backend = BackendFFmpeg
+	}
+	if backend == BackendSox && !soxCanDecode(args.SourceFormat) {
+		log.DebugContext(ctx, "Converting with ffmpeg, sox cannot decode the source",
+			"source_format", args.SourceFormat)
+		backend = BackendFFmpeg
+	}
+	if backend == BackendFFmpeg {
+		return convertFFmpeg(ctx, log, args)
+	}
+	return convertSox(ctx, log, args)
+}
+
+// convertSox converts audio with sox
+func convertSox(ctx context.Context, log *slog.Logger, args ConvertAudioArgs) error {
+	// Source format
+	cmdArgs := []string{"-t", args.SourceFormat}
+	if args.SourceFormat == "raw" {
+		cmdArgs = append(
+			cmdArgs,
+			"-r", strconv.Itoa(args.SampleRate),
+			"-c", strconv.Itoa(args.Channels),
+			"-b", strconv.Itoa(args.BitDepth),
+			"-e", "signed-integer",
+		)
+	}
+	cmdArgs = append(cmdArgs, cmp.Or(args.SourcePath, "-"))
+
+	// Target format
+	cmdArgs = append(cmdArgs, soxTargetArgs(args)...)
+	switch {
+	case args.TargetFormat == "raw":
+		cmdArgs = append(
+			cmdArgs,
+			"-r", strconv.Itoa(args.SampleRate),
+			"-c", strconv.Itoa(args.Channels),
+			"-b", strconv.Itoa(args.BitDepth),
+			"-e", "signed-integer",
+		)
+	case args.TargetFormat == ContainerWAV && args.BitDepth > 0:
+		cmdArgs = append(cmdArgs, "-b", strconv.Itoa(args.BitDepth))
+	}
+	cmdArgs = append(cmdArgs, "-")
+
+	log.DebugContext(ctx, "Running sox convert",
+		"args", cmdArgs,
+		"command", fmt.Sprintf("sox %s", strings.Join(cmdArgs, " ")))
+
+	cmd := exec.CommandContext(ctx, "sox", cmdArgs...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if args.SourcePath == "" {
+		cmd.Stdin = args.Reader
+	}
+	cmd.Stdout = args.Writer
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		log.ErrorContext(ctx, "Sox convert failed",
+			"error", err,
+			"stderr", stderr.String())
+		message := strings.TrimSpace(stderr.String())
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			message = "sox is not installed"
+		case message == "":
+			message = err.Error()
+		}
+		return fmt.Errorf("sox convert failed: %s: %w", message, ErrAudioCaptureFailed)
+	}
+
+	return nil
+}
+
+// soxCanEncode reports whether sox can write the target codec with its settings
+func soxCanEncode(args ConvertAudioArgs) bool {
+	switch {
+	case args.Codec == CodecOpus:
+		return false
+	case args.TargetFormat == ContainerOgg && args.Codec == CodecFLAC:
+		return false
+	case args.Codec == CodecVorbis && args.Bitrate > 0:
+		// sox only selects the Vorbis quality
+		return false
+	default:
+		return true
+	}
+}
+
+// soxCanDecode reports whether sox reads the source format in every build
+func soxCanDecode(format string) bool {
+	switch format {
+	case ContainerRaw, ContainerWAV, ContainerFLAC, ContainerMP3, ContainerAIFF:
+		return true
+	default:
+		return false
+	}
+}
+
+// soxTargetArgs returns the sox file type and compression arguments of the target
+func soxTargetArgs(args ConvertAudioArgs) []string {
+	switch args.Codec {
+	case CodecVorbis:
+		cmdArgs := []string{"-t", "vorbis"}
+		if args.Quality > 0 {
+			cmdArgs = append(cmdArgs, "-C", strconv.Itoa(args.Quality))
+		}
+		return cmdArgs
+	case CodecMP3:
+		cmdArgs := []string{"-t", "mp3"}
+		switch {
+		case args.Bitrate > 0:
+			cmdArgs = append(cmdArgs, "-C", strconv.Itoa(args.Bitrate))
+		case args.Quality > 0:
+			// A negative compression selects a VBR quality, where 0 is the
+			// best. The fraction is the LAME algorithm quality.
+			cmdArgs = append(cmdArgs, "-C", fmt.Sprintf("-%d.2", 10-args.Quality))
+		}
+		return cmdArgs
+	default:
+		return []string{"-t", args.TargetFormat}
+	}
+}
+
+// newSoxRecorder creates a recorder that runs sox with the audio driver of the platform
+func newSoxRecorder(log *slog.Logger, args RecorderArgs) *commandRecorder {
+	cmdArgs := []string{"-t", soxDriver, args.Device.Name}
+
+	// Set sample rate if provided
+	if args.Device.SampleRate > 0 {
+		cmdArgs = append(cmdArgs, "-r", fmt.Sprintf("%d", args.Device.SampleRate))
+	}
+
+	cmdArgs = append(
+		cmdArgs,
+		"-t", "raw",
+		"-e", "signed-integer",
+		"-b", strconv.Itoa(args.BitDepth),
+		"-c", strconv.Itoa(args.Channels),
+	)
+
+	// Use "-" to output to stdout
+	cmdArgs = append(cmdArgs, "-")
+
+	format := WavFormat{
+		SampleRate: args.Device.SampleRate,
+		Channels:   args.Channels,
+		BitDepth:   args.BitDepth,
+	}
+	return newCommandRecorder(log, args.Device, format, "sox", cmdArgs...)
 }
@@ -0,0 +1,53 @@
+package audio
+
+import (
+	"time"
+)
+
+// SpeechMeter implements io.Writer and measures how much of a raw PCM stream
+// is speech. Speech is delimited by the events of the speech detector, so the
+// measurement agrees with auto-stop.
+type SpeechMeter struct {
+	frameSize  int
+	sampleRate int
+	bytes      int
+	speaking   bool
+	start      time.Duration
+	speech     time.Duration
+}
+
+// NewSpeechMeter creates a new SpeechMeter for frames of frameSize bytes
+func NewSpeechMeter(frameSize, sampleRate int) *SpeechMeter {
+	return &SpeechMeter{frameSize: frameSize, sampleRate: sampleRate}
+}
+
+// Write implements io.Writer
+func (m *SpeechMeter) Write(p []byte) (n int, err error) {
+	m.bytes += len(p)
+	return len(p), nil
+}
+
+// Observe accounts for a speech event of the detector
+func (m *SpeechMeter) Observe(event SpeechEvent) {
+	switch {
+	case event.Type == SpeechStart && !m.speaking:
+		m.speaking, m.start = true, event.Offset
+	case event.Type == SpeechEnd && m.speaking:
+		m.speaking = false
+		m.speech += max(event.Offset-m.start, 0)
+	}
+}
+
+// Duration returns the total duration of audio seen so far
+func (m *SpeechMeter) Duration() time.Duration {
+	return framesToDuration(m.bytes/max(m.frameSize, 1), m.sampleRate)
+}
+
+// SpeechDuration returns the duration of speech so far, including speech
+// that has not ended yet
+func (m *SpeechMeter) SpeechDuration() time.Duration {
+	if m.speaking {
+		return m.speech + max(m.Duration()-m.start, 0)
+	}
+	return m.speech
+}
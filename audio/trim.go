@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"io"
+	"time"
+)
+
+// trimHoldLimit is the most silence held after speech has ended. Longer
+// silences between speech segments are shortened to bound memory use.
+const trimHoldLimit = 30 * time.Second
+
+// TrailingTrimmer implements io.Writer and removes trailing silence from a raw
+// PCM stream. Output is delayed by a fixed amount so that the silence which
+// precedes a SpeechEnd event has not yet been written when the event arrives.
+// Silence between speech segments is kept, up to trimHoldLimit.
+type TrailingTrimmer struct {
+	w          io.Writer
+	frameSize  int
+	sampleRate int
+	pad        time.Duration
+	delay      int // bytes held back while speech is ongoing
+	limit      int // bytes of silence held after speech has ended
+	held       []byte
+	heldPos    int64 // stream position of held[0]
+	cutAt      int64 // stream position where trailing silence starts, or -1
+	dropped    int64
+}
+
+// NewTrailingTrimmer creates a trimmer writing to w. The delay must be at
+// least the time it takes the detector to report the end of speech, and pad
+// is the amount of silence kept after speech.
+func NewTrailingTrimmer(w io.Writer, frameSize, sampleRate int, delay, pad time.Duration) *TrailingTrimmer {
+	delayFrames := int(delay.Seconds() * float64(sampleRate))
+	limitFrames := int(trimHoldLimit.Seconds() * float64(sampleRate))
+	return &TrailingTrimmer{
+		w:          w,
+		frameSize:  frameSize,
+		sampleRate: sampleRate,
+		pad:        pad,
+		delay:      delayFrames * frameSize,
+		limit:      max(limitFrames, 2*delayFrames) * frameSize,
+		cutAt:      -1,
+	}
+}
+
+// SpeechEnded marks the stream offset where speech ended. Unless speech
+// starts again, audio after the offset and pad is dropped.
+func (t *TrailingTrimmer) SpeechEnded(offset time.Duration) {
+	frames := int64((offset + t.pad).Seconds() * float64(t.sampleRate))
+	t.cutAt = frames * int64(t.frameSize)
+}
+
+// SpeechStarted cancels a pending cut, keeping the silence between speech segments
+func (t *TrailingTrimmer) SpeechStarted() {
+	t.cutAt = -1
+}
+
+// Dropped returns the duration of silence removed
+func (t *TrailingTrimmer) Dropped() time.Duration {
+	return framesToDuration(int(t.dropped)/t.frameSize, t.sampleRate)
+}
+
+// Write implements io.Writer
+func (t *TrailingTrimmer) Write(p []byte) (int, error) {
+	t.held = append(t.held, p...)
+
+	// Everything is held after speech has ended, since it may all be trimmed
+	if t.cutAt >= 0 {
+		return len(p), t.shorten()
+	}
+	n := len(t.held) - t.delay
+	n -= n % t.frameSize
+	if n <= 0 {
+		return len(p), nil
+	}
+	if _, err := t.w.Write(t.held[:n]); err != nil {
+		return 0, err
+	}
+	t.held = append(t.held[:0], t.held[n:]...)
+	t.heldPos += int64(n)
+	return len(p), nil
+}
+
+// shorten limits the silence held after speech has ended. The speech before
+// the cut is written, and only the most recent delay of the silence is kept
+// in case speech starts again.
+func (t *TrailingTrimmer) shorten() error {
+	cut := int(min(max(t.cutAt-t.heldPos, 0), int64(len(t.held))))
+	if len(t.held)-cut <= t.limit {
+		return nil
+	}
+	if _, err := t.w.Write(t.held[:cut]); err != nil {
+		return err
+	}
+	drop := len(t.held) - t.delay - cut
+	drop -= drop % t.frameSize
+	t.dropped += int64(drop)
+	t.held = append(t.held[:0], t.held[cut+drop:]...)
+	t.heldPos += int64(cut + drop)
+	return nil
+}
+
+// Close writes the held audio up to the trailing silence. The underlying
+// writer is not closed.
+func (t *TrailingTrimmer) Close() error {
+	keep := len(t.held)
+	if t.cutAt >= 0 {
+		keep = int(min(max(t.cutAt-t.heldPos, 0), int64(keep)))
+	}
+	t.dropped += int64(len(t.held) - keep)
+	data := t.held[:keep]
+	t.held = nil
+	if _, err := t.w.Write(data); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"time"
+)
+
+const (
+	// vadBandLow and vadBandHigh bound the speech band in Hz
+	vadBandLow  = 300
+	vadBandHigh = 3400
+	// vadStartProbability is the smoothed speech probability required to start speech
+	vadStartProbability = 0.7
+	// vadSustainProbability is the smoothed speech probability that keeps speech going
+	vadSustainProbability = 0.4
+	// vadSmoothing is the weight of the previous probability when smoothing
+	vadSmoothing = 0.5
+)
+
+// vadModel holds the weights of the logistic speech model. The features are
+// SNR in dB (capped), the share of energy in the speech band, the spectral
+// flatness of the speech band and the zero crossing rate in kHz above a
+// typical speech rate.
+var vadModel = struct {
+	bias, snr, bandRatio, flatness, zcr float64
+	maxSNR, speechZCR                   float64
+}{
+	bias:      -5,
+	snr:       0.15,
+	bandRatio: 6,
+	flatness:  -8,
+	zcr:       -0.4,
+	maxSNR:    30,
+	speechZCR: 3,
+}
+
+// VADArgs holds the arguments for NewVAD
+type VADArgs struct {
+	Format     SampleFormat
+	Channels   int
+	SampleRate int
+	// Window is the analysis window, defaults to 20ms
+	Window time.Duration
+	// MinLevel is the RMS level in dBFS below which a window is always silence
+	MinLevel float64
+	// Hangover is how long silence must last before speech ends
+	Hangover time.Duration
+}
+
+// VADFeatures holds the features extracted from a window
+type VADFeatures struct {
+	// Level is the RMS level in dBFS
+	Level float64
+	// SNR is the level above the estimated noise floor in dB
+	SNR float64
+	// BandRatio is the share of energy between 300 and 3400 Hz
+	BandRatio float64
+	// Flatness is the spectral flatness of the speech band, near 1 for noise
+	// and near 0 for tonal sounds such as voiced speech
+	Flatness float64
+	// ZCR is the zero crossing rate in crossings per millisecond
+	ZCR float64
+}
+
+// VAD implements SpeechDetector with a spectral voice activity detector.
+//
+// Each window is downmixed to mono and scored by a small logistic model over
+// band energy, spectral flatness, zero crossing rate and SNR. Broadband noise
+// such as keyboard clicks is flat with a high crossing rate, and steady noise
+// such as HVAC is absorbed by the noise floor, while voiced speech is tonal
+// with most of its energy in the speech band.
+type VAD struct {
+	args        VADArgs
+	decoder     *frameDecoder
+	tracker     *speechTracker
+	window      []float64
+	windowPos   int
+	hann        []float64
+	spectrum    []complex128
+	twiddles    []complex128
+	noise       noiseFloor
+	probability float64
+}
+
+// NewVAD creates a new VAD that calls callback for each speech event
+func NewVAD(args VADArgs, callback func(SpeechEvent)) *VAD {
+	size := windowFrames(args.Window, args.SampleRate)
+	fftSize := 1 << bits.Len(uint(size-1))
+
+	hann := make([]float64, size)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(max(size-1, 1)))
+	}
+	twiddles := make([]complex128, fftSize/2)
+	for i := range twiddles {
+		twiddles[i] = cmplx.Exp(complex(0, -2*math.Pi*float64(i)/float64(fftSize)))
+	}
+
+	return &VAD{
+		args:     args,
+		decoder:  newFrameDecoder(args.Format, args.Channels),
+		tracker:  newSpeechTracker(size, args.SampleRate, args.Hangover, callback),
+		window:   make([]float64, size),
+		hann:     hann,
+		spectrum: make([]complex128, fftSize),
+		twiddles: twiddles,
+		noise:    newNoiseFloor(size, args.SampleRate),
+	}
+}
+
+// Write implements io.Writer
+func (v *VAD) Write(p []byte) (n int, err error) {
+	v.decoder.decode(p, func(frame []float64) {
+		var sum float64
+		for _, val := range frame {
+			sum += val
+		}
+		v.window[v.windowPos] = sum / float64(len(frame))
+		v.windowPos++
+		if v.windowPos == len(v.window) {
+			v.analyzeWindow()
+			v.windowPos = 0
+		}
+	})
+	return len(p), nil
+}
+
+// Speaking implements SpeechDetector
+func (v *VAD) Speaking() bool {
+	return v.tracker.speaking
+}
+
+// SilenceRemaining implements SpeechDetector
+func (v *VAD) SilenceRemaining() (time.Duration, bool) {
+	return v.tracker.silenceRemaining()
+}
+
+// analyzeWindow scores the current window and updates the speech state
+func (v *VAD) analyzeWindow() {
+	features := v.features()
+	p := 0.0
+	if features.Level >= v.args.MinLevel {
+		p = SpeechProbability(features)
+	}
+	v.probability = vadSmoothing*v.probability + (1-vadSmoothing)*p
+
+	if !v.tracker.speaking && p < 0.5 {
+		v.noise.update(features.Level)
+	}
+	v.tracker.update(v.probability >= vadStartProbability, v.probability >= vadSustainProbability)
+}
+
+// features extracts the features of the current window
+func (v *VAD) features() VADFeatures {
+	var f VADFeatures
+
+	var sumSquare float64
+	crossings := 0
+	for i, val := range v.window {
+		sumSquare += val * val
+		if i > 0 && (val >= 0) != (v.window[i-1] >= 0) {
+			crossings++
+		}
+	}
+	f.Level = max(toDBFS(math.Sqrt(sumSquare/float64(len(v.window)))), detectorFloorDBFS)
+	f.ZCR = float64(crossings) / framesToDuration(len(v.window), v.args.SampleRate).Seconds() / 1000
+	if floor := v.noise.value(); !math.IsNaN(floor) {
+		f.SNR = f.Level - floor
+	}
+
+	for i := range v.spectrum {
+		v.spectrum[i] = 0
+	}
+	for i, val := range v.window {
+		v.spectrum[i] = complex(val*v.hann[i], 0)
+	}
+	fft(v.spectrum, v.twiddles)
+
+	// Sum the power over the full and speech bands, skipping DC
+	const eps = 1e-12
+	binHz := float64(v.args.SampleRate) / float64(len(v.spectrum))
+	var total, band, logBand float64
+	bandBins := 0
+	for k := 1; k <= len(v.spectrum)/2; k++ {
+		c := v.spectrum[k]
+		power := real(c)*real(c) + imag(c)*imag(c) + eps
+		total += power
+		if hz := float64(k) * binHz; hz >= vadBandLow && hz <= vadBandHigh {
+			band += power
+			logBand += math.Log(power)
+			bandBins++
+		}
+	}
+	if total > 0 {
+		f.BandRatio = band / total
+	}
+	if bandBins > 0 {
+		f.Flatness = math.Exp(logBand/float64(bandBins)) / (band / float64(bandBins))
+	}
+	return f
+}
+
+// SpeechProbability scores window features with the VAD decision model
+func SpeechProbability(f VADFeatures) float64 {
+	m := vadModel
+	z := m.bias +
+		m.snr*min(max(f.SNR, 0), m.maxSNR) +
+		m.bandRatio*f.BandRatio +
+		m.flatness*f.Flatness +
+		m.zcr*max(f.ZCR-m.speechZCR, 0)
+	return 1 / (1 + math.Exp(-z))
+}
+
+// fft computes the discrete Fourier transform of x in place. The length of x
+// must be a power of two and twiddles must hold exp(-2πik/n) for k < n/2.
+func fft(x []complex128, twiddles []complex128) {
+	n := len(x)
+	if n < 2 {
+		return
+	}
+
+	// Bit reversal permutation
+	shift := bits.UintSize - bits.Len(uint(n-1))
+	for i := range x {
+		j := int(bits.Reverse(uint(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := n / size
+		for start := 0; start < n; start += size {
+			for k := range half {
+				t := twiddles[k*step] * x[start+k+half]
+				x[start+k+half] = x[start+k] - t
+				x[start+k] += t
+			}
+		}
+	}
+}
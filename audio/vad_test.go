@@ -0,0 +1,218 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	// vadTestRate is the sample rate of the synthetic signals
+	vadTestRate = 16000
+	// vadTestHangover is the hangover used in the tests
+	vadTestHangover = 300 * time.Millisecond
+	// vadTestTolerance is how far detected offsets may be from the labels,
+	// which covers the start windows and the probability smoothing
+	vadTestTolerance = 100 * time.Millisecond
+)
+
+// testSignal generates a number of seconds of mono samples in [-1, 1]
+type testSignal func(seconds float64) []float64
+
+// silence is digital silence
+func silence(seconds float64) []float64 {
+	return make([]float64, int(seconds*vadTestRate))
+}
+
+// whiteNoise is uniform noise at the given RMS level in dBFS
+func whiteNoise(dbfs float64) testSignal {
+	return func(seconds float64) []float64 {
+		amp := math.Pow(10, dbfs/20) * math.Sqrt(3)
+		samples := make([]float64, int(seconds*vadTestRate))
+		seed := uint32(7)
+		for i := range samples {
+			seed = seed*1664525 + 1013904223
+			samples[i] = amp * (float64(seed)/(1<<31) - 1)
+		}
+		return samples
+	}
+}
+
+// voiced approximates voiced speech: harmonics of a 120 Hz fundamental with
+// vibrato, shaped by two formants and modulated at a syllable rate
+func voiced(seconds float64) []float64 {
+	samples := make([]float64, int(seconds*vadTestRate))
+	var phase float64
+	for i := range samples {
+		t := float64(i) / vadTestRate
+		f0 := 120 + 8*math.Sin(2*math.Pi*5*t)
+		phase += 2 * math.Pi * f0 / vadTestRate
+		var v float64
+		for k := 1; float64(k)*f0 < 3400; k++ {
+			hz := float64(k) * f0
+			gain := math.Exp(-math.Pow((hz-700)/250, 2)) + 0.6*math.Exp(-math.Pow((hz-1800)/350, 2)) + 0.05
+			v += gain * math.Sin(float64(k)*phase)
+		}
+		envelope := 0.6 + 0.4*math.Sin(2*math.Pi*4*t)
+		samples[i] = 0.1 * envelope * v
+	}
+	return samples
+}
+
+// keyboardClicks are 5ms bursts of decaying noise every 150ms
+func keyboardClicks(seconds float64) []float64 {
+	samples := make([]float64, int(seconds*vadTestRate))
+	period := int(0.15 * vadTestRate)
+	click := int(0.005 * vadTestRate)
+	seed := uint32(3)
+	for i := range samples {
+		pos := i % period
+		if pos >= click {
+			continue
+		}
+		seed = seed*1664525 + 1013904223
+		decay := math.Exp(-float64(pos) / float64(click) * 4)
+		samples[i] = 0.5 * decay * (float64(seed)/(1<<31) - 1)
+	}
+	return samples
+}
+
+// encodePCM encodes mono samples as 16-bit signed little-endian PCM
+func encodePCM(samples []float64) []byte {
+	format := SignedPCM(16)
+	pcm := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		format.Encode(pcm[2*i:], v)
+	}
+	return pcm
+}
+
+// detectSpeech runs the VAD over PCM in chunks and returns the speech events
+func detectSpeech(t *testing.T, pcm []byte, format WavFormat) []SpeechEvent {
+	t.Helper()
+	var events []SpeechEvent
+	detector, err := NewSpeechDetector(SpeechDetectorArgs{
+		Engine:         EngineVAD,
+		Format:         SignedPCM(format.BitDepth),
+		Channels:       format.Channels,
+		SampleRate:     format.SampleRate,
+		StartThreshold: -40,
+		Hysteresis:     6,
+		Hangover:       vadTestHangover,
+	}, func(e SpeechEvent) { events = append(events, e) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rest := pcm; len(rest) > 0; {
+		n := min(len(rest), 4000)
+		if _, err := detector.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	return events
+}
+
+// checkEvents compares speech events with the wanted events, allowing
+// vadTestTolerance between offsets
+func checkEvents(t *testing.T, got, want []SpeechEvent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	for i := range want {
+		diff := got[i].Offset - want[i].Offset
+		if got[i].Type != want[i].Type || diff.Abs() > vadTestTolerance {
+			t.Errorf("event %d is %v at %v, want %v at %v", i, got[i].Type, got[i].Offset, want[i].Type, want[i].Offset)
+		}
+	}
+}
+
+func Test_VAD_synthetic(t *testing.T) {
+	type segment struct {
+		signal  testSignal
+		seconds float64
+	}
+	// The initial speech end reports that the capture starts in silence
+	initialSilence := SpeechEvent{Type: SpeechEnd}
+	for _, tc := range []struct {
+		name     string
+		segments []segment
+		want     []SpeechEvent
+	}{
+		{
+			name:     "silence",
+			segments: []segment{{silence, 3}},
+			want:     []SpeechEvent{initialSilence},
+		},
+		{
+			name:     "white noise",
+			segments: []segment{{silence, 0.5}, {whiteNoise(-30), 2.5}},
+			want:     []SpeechEvent{initialSilence},
+		},
+		{
+			name:     "keyboard clicks",
+			segments: []segment{{silence, 0.5}, {keyboardClicks, 2.5}},
+			want:     []SpeechEvent{initialSilence},
+		},
+		{
+			name:     "voiced speech in silence",
+			segments: []segment{{silence, 1}, {voiced, 1.5}, {silence, 1}},
+			want: []SpeechEvent{
+				initialSilence,
+				{Type: SpeechStart, Offset: time.Second},
+				{Type: SpeechEnd, Offset: 2500 * time.Millisecond},
+			},
+		},
+		{
+			name:     "voiced speech between keyboard clicks",
+			segments: []segment{{keyboardClicks, 1}, {voiced, 1.5}, {keyboardClicks, 1}},
+			want: []SpeechEvent{
+				initialSilence,
+				{Type: SpeechStart, Offset: time.Second},
+				{Type: SpeechEnd, Offset: 2500 * time.Millisecond},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var samples []float64
+			for _, s := range tc.segments {
+				samples = append(samples, s.signal(s.seconds)...)
+			}
+			format := WavFormat{SampleRate: vadTestRate, Channels: 1, BitDepth: 16}
+			checkEvents(t, detectSpeech(t, encodePCM(samples), format), tc.want)
+		})
+	}
+}
+
+func Test_VAD_recordedSpeech(t *testing.T) {
+	f, err := os.Open("testdata/speech.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	wav, err := NewWavReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, err := io.ReadAll(wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The recording starts with half a second of silence, which is reported
+	// before speech starts
+	events := detectSpeech(t, pcm, wav.Format)
+	if len(events) < 3 {
+		t.Fatalf("got events %v, want speech", events)
+	}
+	// Pauses between words may split the speech, so only the first start and
+	// the last end are compared with the labels
+	checkEvents(t, []SpeechEvent{events[0], events[1], events[len(events)-1]}, []SpeechEvent{
+		{Type: SpeechEnd},
+		{Type: SpeechStart, Offset: 580 * time.Millisecond},
+		{Type: SpeechEnd, Offset: 4670 * time.Millisecond},
+	})
+}
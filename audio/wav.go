@@ -0,0 +1,329 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV format codes
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatIEEEFloat  = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavUnknownSize is the chunk size used when the size is not known up front,
+// e.g. when streaming to a pipe
+const wavUnknownSize = math.MaxUint32
+
+// wavSubformatSuffix is the common suffix of the KSDATAFORMAT_SUBTYPE GUIDs
+// used by WAVE_FORMAT_EXTENSIBLE. The first two bytes hold the format code.
+var wavSubformatSuffix = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+}
+
+// WavFormat describes the sample format of a WAV stream
+type WavFormat struct {
+	SampleRate int
+	Channels   int
+	BitDepth   int
+	// Float is set for IEEE floating point samples
+	Float bool
+}
+
+// blockAlign returns the size of a frame in bytes
+func (f WavFormat) blockAlign() int {
+	return f.Channels * f.BitDepth / 8
+}
+
+// extensible reports whether the format requires WAVE_FORMAT_EXTENSIBLE
+func (f WavFormat) extensible() bool {
+	return f.Channels > 2 || f.BitDepth > 16
+}
+
+// WavWriter implements io.WriteCloser and writes raw signed little-endian PCM
+// as a WAV stream. 8-bit samples are converted to the unsigned encoding that
+// WAV uses at that depth.
+//
+// If the underlying writer is seekable, the header sizes are patched on
+// Close. Otherwise the sizes are written as 0xFFFFFFFF, which is the common
+// convention for streamed WAV of unknown length.
+type WavWriter struct {
+	w          io.Writer
+	seeker     io.WriteSeeker
+	format     WavFormat
+	headerSize int
+	dataSize   int64
+	// buf holds 8-bit samples converted to unsigned
+	buf []byte
+}
+
+// NewWavWriter writes a WAV header to w and returns a writer for the sample data
+func NewWavWriter(w io.Writer, format WavFormat) (*WavWriter, error) {
+	ww := &WavWriter{w: w, format: format}
+
+	// Files are seekable, pipes such as stdout are not
+	if seeker, ok := w.(io.WriteSeeker); ok {
+		if _, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			ww.seeker = seeker
+		}
+	}
+
+	header := ww.header(wavUnknownSize)
+	ww.headerSize = len(header)
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("write wav header: %w", err)
+	}
+	return ww, nil
+}
+
+// header returns the WAV header for the given data size
+func (w *WavWriter) header(dataSize uint32) []byte {
+	f := w.format
+	formatCode := uint16(wavFormatPCM)
+	if f.Float {
+		formatCode = wavFormatIEEEFloat
+	}
+
+	var fmtChunk bytes.Buffer
+	le := binary.LittleEndian
+	if f.extensible() {
+		_ = binary.Write(&fmtChunk, le, uint16(wavFormatExtensible))
+	} else {
+		_ = binary.Write(&fmtChunk, le, formatCode)
+	}
+	_ = binary.Write(&fmtChunk, le, uint16(f.Channels))
+	_ = binary.Write(&fmtChunk, le, uint32(f.SampleRate))
+	_ = binary.Write(&fmtChunk, le, uint32(f.SampleRate*f.blockAlign()))
+	_ = binary.Write(&fmtChunk, le, uint16(f.blockAlign()))
+	_ = binary.Write(&fmtChunk, le, uint16(f.BitDepth))
+	if f.extensible() {
+		_ = binary.Write(&fmtChunk, le, uint16(22))         // extension size
+		_ = binary.Write(&fmtChunk, le, uint16(f.BitDepth)) // valid bits per sample
+		_ = binary.Write(&fmtChunk, le, defaultChannelMask(f.Channels))
+		_ = binary.Write(&fmtChunk, le, formatCode)
+		fmtChunk.Write(wavSubformatSuffix)
+	}
+
+	var buf bytes.Buffer
+	riffSize := uint32(wavUnknownSize)
+	if dataSize != wavUnknownSize {
+		chunksSize := int64(4+8+fmtChunk.Len()+8) + int64(dataSize) + int64(dataSize%2)
+		riffSize = uint32(min(chunksSize, wavUnknownSize))
+	}
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, le, riffSize)
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	_ = binary.Write(&buf, le, uint32(fmtChunk.Len()))
+	buf.Write(fmtChunk.Bytes())
+	buf.WriteString("data")
+	_ = binary.Write(&buf, le, dataSize)
+	return buf.Bytes()
+}
+
+// defaultChannelMask returns the speaker position mask for the channel count
+func defaultChannelMask(channels int) uint32 {
+	switch channels {
+	case 1:
+		return 0x4 // front center
+	case 2:
+		return 0x3 // front left, front right
+	default:
+		return 0 // unspecified
+	}
+}
+
+// Write implements io.Writer
+func (w *WavWriter) Write(p []byte) (int, error) {
+	if w.format.BitDepth == 8 && !w.format.Float {
+		w.buf = w.buf[:0]
+		for _, b := range p {
+			w.buf = append(w.buf, b^0x80)
+		}
+		p = w.buf
+	}
+	n, err := w.w.Write(p)
+	w.dataSize += int64(n)
+	return n, err
+}
+
+// Close finalizes the WAV stream by padding the data chunk and, when the
+// underlying writer is seekable, patching the header sizes. The underlying
+// writer is not closed.
+func (w *WavWriter) Close() error {
+	if w.dataSize%2 == 1 {
+		if _, err := w.w.Write([]byte{0}); err != nil {
+			return fmt.Errorf("write wav padding: %w", err)
+		}
+	}
+	if w.seeker == nil {
+		return nil
+	}
+
+	end, err := w.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek wav end: %w", err)
+	}
+	start := end - w.dataSize - w.dataSize%2 - int64(w.headerSize)
+	if _, err := w.seeker.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("seek wav header: %w", err)
+	}
+	if _, err := w.seeker.Write(w.header(uint32(min(w.dataSize, wavUnknownSize-1)))); err != nil {
+		return fmt.Errorf("patch wav header: %w", err)
+	}
+	if _, err := w.seeker.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("seek wav end: %w", err)
+	}
+	return nil
+}
+
+// WavReader implements io.Reader and reads the sample data of a WAV stream
+type WavReader struct {
+	r      io.Reader
+	Format WavFormat
+	// remaining is the number of data bytes left, or -1 if unknown
+	remaining int64
+}
+
+// NewWavReader reads the WAV header from r and returns a reader positioned at the start of the sample data
+func NewWavReader(r io.Reader) (*WavReader, error) {
+	le := binary.LittleEndian
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("read riff header: %w: %w", err, ErrInvalidWav)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a RIFF/WAVE stream: %w", ErrInvalidWav)
+	}
+
+	wr := &WavReader{r: r}
+	var haveFormat bool
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("read chunk header: %w: %w", err, ErrInvalidWav)
+		}
+		id := string(chunk[0:4])
+		size := le.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("fmt chunk too small: %w", ErrInvalidWav)
+			}
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("read fmt chunk: %w: %w", err, ErrInvalidWav)
+			}
+			format, err := parseWavFormat(data[:size])
+			if err != nil {
+				return nil, err
+			}
+			wr.Format = format
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("data chunk before fmt chunk: %w", ErrInvalidWav)
+			}
+			wr.remaining = int64(size)
+			// Streamed files use 0 or 0xFFFFFFFF when the size is unknown
+			if size == 0 || size == wavUnknownSize {
+				wr.remaining = -1
+			}
+			return wr, nil
+		default:
+			// Skip unknown chunks, which are padded to an even size
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, fmt.Errorf("skip %q chunk: %w: %w", id, err, ErrInvalidWav)
+			}
+		}
+	}
+}
+
+// parseWavFormat parses the contents of a fmt chunk
+func parseWavFormat(data []byte) (WavFormat, error) {
+	le := binary.LittleEndian
+	formatCode := le.Uint16(data[0:2])
+	format := WavFormat{
+		Channels:   int(le.Uint16(data[2:4])),
+		SampleRate: int(le.Uint32(data[4:8])),
+		BitDepth:   int(le.Uint16(data[14:16])),
+	}
+	if formatCode == wavFormatExtensible {
+		if len(data) < 40 {
+			return WavFormat{}, fmt.Errorf("extensible fmt chunk too small: %w", ErrInvalidWav)
+		}
+		if !bytes.Equal(data[26:40], wavSubformatSuffix) {
+			return WavFormat{}, fmt.Errorf("unsupported extensible subformat: %w", ErrInvalidWav)
+		}
+		formatCode = le.Uint16(data[24:26])
+	}
+	switch formatCode {
+	case wavFormatPCM:
+	case wavFormatIEEEFloat:
+		format.Float = true
+	default:
+		return WavFormat{}, fmt.Errorf("unsupported format code 0x%04x: %w", formatCode, ErrInvalidWav)
+	}
+	if format.Channels == 0 || format.BitDepth == 0 || format.BitDepth%8 != 0 {
+		return WavFormat{}, fmt.Errorf(
+			"unsupported %d channels at %d bits: %w", format.Channels, format.BitDepth, ErrInvalidWav)
+	}
+	return format, nil
+}
+
+// Read implements io.Reader
+func (r *WavReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if r.remaining > 0 && int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	if r.remaining > 0 {
+		r.remaining -= int64(n)
+	}
+	return n, err
+}
+
+// convertNative converts raw PCM to WAV, FLAC or raw PCM without an external
+// process. It reports false if the conversion is not supported natively.
+func convertNative(args ConvertAudioArgs) (bool, error) {
+	if args.SourceFormat != "raw" {
+		return false, nil
+	}
+	if args.TargetFormat == ContainerRaw {
+		if _, err := io.Copy(args.Writer, args.Reader); err != nil {
+			return true, fmt.Errorf("write raw data: %w", err)
+		}
+		return true, nil
+	}
+	format := WavFormat{
+		SampleRate: args.SampleRate,
+		Channels:   args.Channels,
+		BitDepth:   args.BitDepth,
+	}
+
+	var w io.WriteCloser
+	var err error
+	switch args.TargetFormat {
+	case "wav":
+		w, err = NewWavWriter(args.Writer, format)
+	case "flac":
+		w, err = NewFlacWriter(args.Writer, format)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if _, err := io.Copy(w, args.Reader); err != nil {
+		return true, fmt.Errorf("write %s data: %w", args.TargetFormat, err)
+	}
+	return true, w.Close()
+}
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sebnyberg/flagtags"
+	"github.com/sebnyberg/sttrouter/audio"
+	"github.com/urfave/cli/v2"
+)
+
+// calibrationSentence is read aloud during calibration
+const calibrationSentence = "The quick brown fox jumps over the lazy dog, then rests for a moment in the shade."
+
+// CalibrateConfig holds calibrate specific configuration flags.
+type CalibrateConfig struct {
+	// Device specifies the audio device name (defaults to system default)
+	Device string `name:"device" usage:"Audio device, or a file:, stdin or synth: source (defaults to system default)"`
+	// SampleRate specifies the sample rate in Hz (overrides device default)
+	SampleRate int `name:"rate" usage:"Sample rate in Hz (overrides device default)"`
+	// Channels specifies the number of audio channels
+	Channels int `name:"channels" value:"2" usage:"Number of audio channels"`
+	// Backend selects how audio is recorded from the device
+	Backend string `name:"backend" value:"auto" usage:"Recorder backend (auto, sox, ffmpeg, pipewire, alsa)"`
+	// NoiseDuration specifies how long to record room tone
+	NoiseDuration string `name:"noise-duration" value:"3s" usage:"Duration of the room tone recording"`
+	// SpeechDuration specifies how long to record the spoken sentence
+	SpeechDuration string `name:"speech-duration" value:"6s" usage:"Duration of the speech recording"`
+	// Save stores the recommended settings for the device
+	Save bool `name:"save" usage:"Save the recommended settings for the device"`
+	// ProfileFile specifies where device profiles are saved
+	ProfileFile string `name:"profile-file" usage:"Device profile file (defaults to the user config directory)"`
+}
+
+// validate validates the calibrate configuration.
+func (c *CalibrateConfig) validate() error {
+	if c.SampleRate < 0 {
+		return fmt.Errorf("sample rate must be >= 0, was '%v", c.SampleRate)
+	}
+	if c.Channels <= 0 || c.Channels > 2 {
+		return fmt.Errorf("channels must be 1 or 2, was '%v'", c.Channels)
+	}
+	if err := validateBackend(c.Backend); err != nil {
+		return err
+	}
+	if d, err := time.ParseDuration(c.NoiseDuration); err != nil || d < time.Second {
+		return fmt.Errorf("invalid noise duration '%v', must be at least 1s", c.NoiseDuration)
+	}
+	if d, err := time.ParseDuration(c.SpeechDuration); err != nil || d < time.Second {
+		return fmt.Errorf("invalid speech duration '%v', must be at least 1s", c.SpeechDuration)
+	}
+	return nil
+}
+
+// deviceProfile holds calibrated auto-stop settings for an audio device
+type deviceProfile struct {
+	AutoStopThreshold   float64   `json:"auto_stop_threshold"`
+	AutoStopMinDuration string    `json:"auto_stop_min_duration"`
+	CalibratedAt        time.Time `json:"calibrated_at"`
+}
+
+// defaultProfilePath returns the default location for device profiles
+func defaultProfilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sttrouter", "devices.json"), nil
+}
+
+// profilePath returns path, or the default location if path is empty
+func profilePath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return defaultProfilePath()
+}
+
+func readDeviceProfiles(path string) (map[string]deviceProfile, error) {
+	profiles := make(map[string]deviceProfile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("parse device profiles '%s': %w", path, err)
+	}
+	return profiles, nil
+}
+
+func writeDeviceProfiles(path string, profiles map[string]deviceProfile) error {
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so concurrent readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".devices-*.json")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// applyDeviceProfile sets auto-stop settings from the saved profile of the
+// device, unless they were given on the command line. prefix is prepended to
+// flag names, for commands that nest the capture flags.
+func applyDeviceProfile(config *CaptureConfig, device string, isSet func(string) bool, prefix string) error {
+	path, err := profilePath(config.ProfileFile)
+	if err != nil {
+		return fmt.Errorf("failed to locate device profiles: %w", err)
+	}
+	profiles, err := readDeviceProfiles(path)
+	if err != nil {
+		return fmt.Errorf("failed to read device profiles: %w", err)
+	}
+	profile, ok := profiles[device]
+	if !ok {
+		return nil
+	}
+	if !isSet(prefix + "auto-stop-threshold") {
+		config.AutoStopThreshold = profile.AutoStopThreshold
+	}
+	if !isSet(prefix+"auto-stop-min-duration") && profile.AutoStopMinDuration != "" {
+		config.AutoStopMinDuration = profile.AutoStopMinDuration
+	}
+	slog.Debug("Applied device profile",
+		"device", device,
+		"path", path,
+		"auto_stop_threshold", config.AutoStopThreshold,
+		"auto_stop_min_duration", config.AutoStopMinDuration)
+	return config.validate()
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// recordLevels captures audio for the given duration and returns the level of each window
+func recordLevels(
+	ctx context.Context,
+	logger *slog.Logger,
+	device audio.Device,
+	config *CalibrateConfig,
+	duration time.Duration,
+) ([]float64, time.Duration, error) {
+	const bitDepth = 16
+	recorder, err := audio.NewRecorder(logger, audio.RecorderArgs{
+		Backend:  config.Backend,
+		Device:   device,
+		Channels: config.Channels,
+		BitDepth: bitDepth,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	format := recorder.Format()
+	levels := audio.NewLevelRecorder(audio.SignedPCM(format.BitDepth), format.Channels, format.SampleRate)
+	_, err = audio.LimitedCapture(ctx, logger, recorder, audio.LimitedCaptureArgs{
+		Duration: duration,
+		Writer:   nopWriteCloser{levels},
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("audio capture failed: %w", err)
+	}
+	return levels.Levels(), levels.Window(), nil
+}
+
+// runCalibrate executes the calibration logic.
+func runCalibrate(baseConfig *Config, config *CalibrateConfig) error {
+	// Partial recordings are of no use, so an interrupt aborts calibration
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	logger := baseConfig.getLogger()
+	slog.SetDefault(logger)
+
+	selectedDevice, err := resolveDevice(ctx, config.Backend, config.Device)
+	if err != nil {
+		return err
+	}
+	if config.SampleRate != 0 {
+		selectedDevice.SampleRate = config.SampleRate
+	}
+
+	noiseDuration, err := time.ParseDuration(config.NoiseDuration)
+	if err != nil {
+		return fmt.Errorf("invalid noise duration: %w", err)
+	}
+	speechDuration, err := time.ParseDuration(config.SpeechDuration)
+	if err != nil {
+		return fmt.Errorf("invalid speech duration: %w", err)
+	}
+
+	fmt.Printf("Calibrating device %s\n\n", selectedDevice.Name)
+	fmt.Printf("Recording room tone for %v, please stay quiet...\n", noiseDuration)
+	noise, window, err := recordLevels(ctx, logger, selectedDevice, config, noiseDuration)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
+
+	fmt.Printf("\nNow read this sentence aloud at your normal volume (%v):\n\n  %s\n\n",
+		speechDuration, calibrationSentence)
+	speech, _, err := recordLevels(ctx, logger, selectedDevice, config, speechDuration)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
+
+	result, err := audio.Calibrate(noise, speech, window)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Room tone:      %.1f dBFS (peak %.1f dBFS)\n", result.NoiseFloorDBFS, result.NoisePeakDBFS)
+	fmt.Printf("Speech level:   %.1f dBFS\n", result.SpeechDBFS)
+	fmt.Printf("SNR:            %.1f dB\n", result.SNRDB)
+	fmt.Printf("Longest pause:  %v\n", result.LongestPause)
+	fmt.Println()
+
+	threshold := strconv.FormatFloat(result.Threshold, 'g', 3, 64)
+	minDuration := result.MinDuration.String()
+	fmt.Println("Recommended settings:")
+	fmt.Printf("  --auto-stop-threshold %s --auto-stop-min-duration %s\n", threshold, minDuration)
+	if result.SNRDB < 15 {
+		fmt.Fprintln(os.Stderr, "Warning: low signal-to-noise ratio, consider --auto-stop-engine vad")
+	}
+
+	if !config.Save {
+		return nil
+	}
+	path, err := profilePath(config.ProfileFile)
+	if err != nil {
+		return fmt.Errorf("failed to locate device profiles: %w", err)
+	}
+	profiles, err := readDeviceProfiles(path)
+	if err != nil {
+		return fmt.Errorf("failed to read device profiles: %w", err)
+	}
+	profiles[selectedDevice.Name] = deviceProfile{
+		AutoStopThreshold:   result.Threshold,
+		AutoStopMinDuration: minDuration,
+		CalibratedAt:        time.Now().UTC(),
+	}
+	if err := writeDeviceProfiles(path, profiles); err != nil {
+		return fmt.Errorf("failed to save device profiles: %w", err)
+	}
+	fmt.Printf("\nSaved settings for %s to %s\n", selectedDevice.Name, path)
+	return nil
+}
+
+func NewCalibrateCommand() *cli.Command {
+	var baseConfig Config
+	var calibrateConfig CalibrateConfig
+	baseFlags := flagtags.MustParseFlags(&baseConfig)
+	calibrateFlags := flagtags.MustParseFlags(&calibrateConfig)
+	flags := append(baseFlags, calibrateFlags...)
+
+	return &cli.Command{
+		Name:  "calibrate",
+		Usage: "Measure room noise and speech levels and recommend auto-stop settings",
+		Description: `Measure the ambient noise and speech levels of a microphone and recommend auto-stop settings.
+
+The command first records --noise-duration of room tone, during which you should stay quiet,
+and then --speech-duration while you read a sentence aloud. From the recordings it computes
+the noise floor and speech level, and recommends --auto-stop-threshold and
+--auto-stop-min-duration values.
+
+With --save, the recommendations are stored for the device in a profile file. capture and
+transcribe use the saved values for the device unless the flags are given explicitly.
+
+Examples:
+  # Calibrate the default microphone
+  sttrouter calibrate
+
+  # Calibrate a specific device and save the result
+  sttrouter calibrate --device alsa_input.usb-mic --save`,
+		Flags: flags,
+		Action: func(c *cli.Context) error {
+			if err := baseConfig.validate(); err != nil {
+				return err
+			}
+			if err := calibrateConfig.validate(); err != nil {
+				return err
+			}
+			return runCalibrate(&baseConfig, &calibrateConfig)
+		},
+	}
+}
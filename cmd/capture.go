@@ -6,11 +6,13 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/sebnyberg/flagtags"
 	"github.com/sebnyberg/sttrouter/audio"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 )
 
 // CaptureConfig holds capture specific configuration flags.
@@ -18,19 +20,190 @@ type CaptureConfig struct {
 	// Duration specifies the capture duration (e.g., "10s", "1m")
 	Duration string `name:"duration" value:"0" usage:"Capture duration (e.g., 10s, 1m)"`
 	// Device specifies the audio device name (defaults to system default)
-	Device string `name:"device" usage:"Audio device name (defaults to system default)"`
+	Device string `name:"device" usage:"Audio device, or a file:, stdin or synth: source (defaults to system default)"`
 	// SampleRate specifies the sample rate in Hz (overrides device default)
 	SampleRate int `name:"rate" usage:"Sample rate in Hz (overrides device default)"`
 	// Channels specifies the number of audio channels
 	Channels int `name:"channels" value:"2" usage:"Number of audio channels"`
 	// BitDepth specifies the audio bit depth
 	BitDepth int `name:"bit-depth" value:"16" usage:"Audio bit depth"`
+	// Format specifies the output audio container
+	Format string `name:"format" value:"flac" usage:"Output audio format (flac, wav, ogg, mp3, raw)"`
+	// Codec specifies the codec in the container, defaulting to the format default
+	Codec string `name:"codec" usage:"Audio codec (pcm, flac, opus, vorbis, mp3; defaults to the format default)"`
+	// Bitrate specifies the bitrate of lossy codecs in kbit/s
+	Bitrate int `name:"bitrate" usage:"Bitrate of lossy codecs in kbit/s (0 for the encoder default)"`
+	// CodecQuality specifies the quality of lossy codecs
+	CodecQuality int `name:"codec-quality" usage:"Quality of lossy codecs from 1 to 10 (0 for the encoder default)"`
 	// NoAutoStop disables auto-stop when silence is detected
 	NoAutoStop bool `name:"no-auto-stop" usage:"Disable auto-stop when silence is detected"`
-	// AutoStopThreshold specifies the auto-stop detection threshold (0.0-1.0)
-	AutoStopThreshold float64 `name:"auto-stop-threshold" value:"0.01" usage:"Auto-stop detection threshold (0.0-1.0)"`
+	// Backend selects how audio is recorded from the device
+	Backend string `name:"backend" value:"auto" usage:"Recorder backend (auto, sox, ffmpeg, pipewire, alsa)"`
+	// AutoStopEngine selects the speech detector used for auto-stop
+	AutoStopEngine string `name:"auto-stop-engine" value:"energy" usage:"Auto-stop engine (energy, vad)"`
+	// AutoStopThreshold specifies the RMS level at which speech starts, relative to full scale (0.0-1.0)
+	AutoStopThreshold float64 `name:"auto-stop-threshold" value:"0.01" usage:"Speech start RMS level (0.0-1.0)"`
 	// AutoStopMinDuration specifies the minimum silence duration to trigger stop (e.g., "1s")
 	AutoStopMinDuration string `name:"auto-stop-min-duration" value:"1s" usage:"Minimum silence duration to trigger stop"`
+	// AutoStopHysteresis specifies how far below the threshold the level must fall to count as silence
+	AutoStopHysteresis float64 `name:"auto-stop-hysteresis" value:"6" usage:"Silence level in dB below the threshold"`
+	// AutoStopNoiseMargin keeps the threshold above the estimated noise floor
+	AutoStopNoiseMargin float64 `name:"auto-stop-noise-margin" value:"10" usage:"Min dB above noise floor (0 disables)"`
+	// WaitForSpeech holds back recording until speech is detected
+	WaitForSpeech bool `name:"wait-for-speech" usage:"Start recording when speech is detected"`
+	// PreRoll specifies how much audio from before the detected speech start to keep
+	PreRoll string `name:"pre-roll" value:"300ms" usage:"Audio kept from before speech starts"`
+	// MaxWait specifies how long to wait for speech before giving up
+	MaxWait string `name:"max-wait" value:"30s" usage:"Maximum time to wait for speech (0 = no limit)"`
+	// TrimSilence removes leading and trailing non-speech before encoding
+	TrimSilence bool `name:"trim-silence" usage:"Trim leading and trailing silence"`
+	// TrimPad specifies how much silence to keep around the speech when trimming
+	TrimPad string `name:"trim-pad" value:"200ms" usage:"Silence kept around speech when trimming"`
+	// NoMeter disables the live input level meter on stderr
+	NoMeter bool `name:"no-meter" usage:"Disable the live input level meter"`
+	// ProfileFile specifies the device profiles written by the calibrate command
+	ProfileFile string `name:"profile-file" usage:"Device profile file (defaults to the user config directory)"`
+	// Quality configures the audio quality preflight
+	Quality QualityConfig `name:"quality"`
+}
+
+// QualityConfig holds audio quality preflight thresholds.
+type QualityConfig struct {
+	// Strict aborts when the captured audio violates any threshold
+	Strict bool `name:"strict" usage:"Abort when audio quality is below thresholds"`
+	// MinPeak is the minimum peak level in dBFS
+	MinPeak float64 `name:"min-peak" value:"-40" usage:"Minimum peak level in dBFS"`
+	// MaxClipping is the maximum percentage of samples at full scale
+	MaxClipping float64 `name:"max-clipping" value:"0.1" usage:"Maximum percentage of clipped samples"`
+	// MaxDCOffset is the maximum DC offset relative to full scale
+	MaxDCOffset float64 `name:"max-dc-offset" value:"0.05" usage:"Maximum DC offset relative to full scale"`
+	// MinSNR is the minimum estimated signal-to-noise ratio in dB
+	MinSNR float64 `name:"min-snr" value:"10" usage:"Minimum estimated signal-to-noise ratio in dB"`
+}
+
+func (c *QualityConfig) validate() error {
+	if c.MinPeak > 0 {
+		return fmt.Errorf("quality min peak must be <= 0 dBFS, was '%v'", c.MinPeak)
+	}
+	if c.MaxClipping < 0 || c.MaxClipping > 100 {
+		return fmt.Errorf("quality max clipping must be in the interval [0,100], was '%v'", c.MaxClipping)
+	}
+	if c.MaxDCOffset < 0 || c.MaxDCOffset > 1 {
+		return fmt.Errorf("quality max DC offset must be in the interval [0,1], was '%v'", c.MaxDCOffset)
+	}
+	return nil
+}
+
+// speechDurations holds the parsed speech detection durations of a CaptureConfig
+type speechDurations struct {
+	minSilence time.Duration
+	preRoll    time.Duration
+	maxWait    time.Duration
+	trimPad    time.Duration
+}
+
+// parseSpeechDurations parses the speech detection durations. The minimum
+// silence duration is also used as hangover when waiting for speech or
+// trimming with auto-stop disabled.
+func parseSpeechDurations(c *CaptureConfig) (speechDurations, error) {
+	var d speechDurations
+	var err error
+	if d.minSilence, err = time.ParseDuration(c.AutoStopMinDuration); err != nil {
+		return d, fmt.Errorf("invalid auto-stop min duration: %w", err)
+	}
+	if d.preRoll, err = time.ParseDuration(c.PreRoll); err != nil {
+		return d, fmt.Errorf("invalid pre-roll: %w", err)
+	}
+	if d.maxWait, err = time.ParseDuration(c.MaxWait); err != nil {
+		return d, fmt.Errorf("invalid max wait: %w", err)
+	}
+	if d.trimPad, err = time.ParseDuration(c.TrimPad); err != nil {
+		return d, fmt.Errorf("invalid trim pad: %w", err)
+	}
+	return d, nil
+}
+
+// validateBackend validates a recorder backend name
+func validateBackend(backend string) error {
+	switch backend {
+	case audio.BackendAuto, audio.BackendSox, audio.BackendFFmpeg, audio.BackendPipeWire, audio.BackendALSA:
+		return nil
+	default:
+		return fmt.Errorf("invalid backend: %s (valid values: auto, sox, ffmpeg, pipewire, alsa)", backend)
+	}
+}
+
+// inferFormat sets the output format, and the codec if the extension names
+// one, from the extension of the output file unless they are set by flags
+func inferFormat(c *CaptureConfig, isSet func(string) bool, prefix, outputFile string) {
+	container, codec, ok := audio.FormatFromPath(outputFile)
+	if !ok || isSet(prefix+"format") {
+		return
+	}
+	c.Format = container
+	if codec != "" && !isSet(prefix+"codec") {
+		c.Codec = codec
+	}
+}
+
+// resolveDevice returns the named device, or the default source if name is
+// empty. File, stdin and synthetic sources are used without listing devices.
+func resolveDevice(ctx context.Context, backend, name string) (audio.Device, error) {
+	if dev, ok := audio.SourceDevice(name); ok {
+		return dev, nil
+	}
+
+	lister, err := audio.NewBackendDeviceLister(ctx, backend)
+	if err != nil {
+		return audio.Device{}, fmt.Errorf("failed to initialize device lister: %w", err)
+	}
+	devices := lister.ListDevices()
+
+	if name == "" {
+		dev, err := audio.GetDefaultSource(devices)
+		if err != nil {
+			return audio.Device{}, fmt.Errorf("failed to get default source device: %w", err)
+		}
+		return dev, nil
+	}
+	dev, err := audio.GetDevice(name, devices)
+	if err != nil {
+		return audio.Device{}, fmt.Errorf("device '%s' not found", name)
+	}
+	return dev, nil
+}
+
+// meterOutput returns where to render the live level meter, or nil when it is
+// disabled or stderr is not a terminal
+func meterOutput(c *CaptureConfig) io.Writer {
+	if c.NoMeter || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return os.Stderr
+}
+
+// isTerminal reports whether f is a terminal. Other character devices such
+// as /dev/null are not.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// checkQuality prints a warning to stderr for each quality problem in the
+// report. In strict mode, problems are returned as an error.
+func checkQuality(config *QualityConfig, report audio.QualityReport) error {
+	problems := report.Problems(audio.QualityThresholds{
+		MinPeakDBFS:        config.MinPeak,
+		MaxClippingPercent: config.MaxClipping,
+		MaxDCOffset:        config.MaxDCOffset,
+		MinSNRDB:           config.MinSNR,
+	})
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+	}
+	if config.Strict && len(problems) > 0 {
+		return fmt.Errorf("%s: %w", strings.Join(problems, "; "), audio.ErrPoorAudioQuality)
+	}
+	return nil
 }
 
 func (c *CaptureConfig) validate() error {
@@ -45,8 +218,30 @@ func (c *CaptureConfig) validate() error {
 	if c.Channels <= 0 || c.Channels > 2 {
 		return fmt.Errorf("channels must be 1 or 2, was '%v'", c.Channels)
 	}
-	if c.BitDepth <= 0 {
-		return fmt.Errorf("bit depth must be greater than 0, was '%v'", c.BitDepth)
+	if err := audio.SignedPCM(c.BitDepth).Validate(); err != nil {
+		return fmt.Errorf("bit depth must be 8, 16, 24 or 32, was '%v'", c.BitDepth)
+	}
+	switch c.Format {
+	case audio.ContainerFLAC, audio.ContainerWAV, audio.ContainerOgg, audio.ContainerMP3, audio.ContainerRaw:
+		// Valid formats
+	default:
+		return fmt.Errorf("invalid format: %s (valid values: flac, wav, ogg, mp3, raw)", c.Format)
+	}
+	codec, err := audio.ResolveCodec(c.Format, c.Codec)
+	if err != nil {
+		return fmt.Errorf("invalid codec: %w", err)
+	}
+	if codec == audio.CodecFLAC && c.BitDepth > 24 {
+		return fmt.Errorf("flac supports bit depths up to 24, was '%v'", c.BitDepth)
+	}
+	if c.Bitrate < 0 {
+		return fmt.Errorf("bitrate must be >= 0, was '%v'", c.Bitrate)
+	}
+	if c.CodecQuality < 0 || c.CodecQuality > 10 {
+		return fmt.Errorf("codec quality must be in the interval [0,10], was '%v'", c.CodecQuality)
+	}
+	if !audio.IsLossyCodec(codec) && (c.Bitrate != 0 || c.CodecQuality != 0) {
+		return fmt.Errorf("bitrate and codec quality only apply to lossy codecs (opus, vorbis, mp3), not %s", codec)
 	}
 	const eps = 1e-5
 	if c.AutoStopThreshold <= 0 || c.AutoStopThreshold > 1.0+eps {
@@ -55,36 +250,54 @@ func (c *CaptureConfig) validate() error {
 	if _, err := time.ParseDuration(c.AutoStopMinDuration); err != nil {
 		return fmt.Errorf("invalid auto-stop min duration '%v', %w", c.AutoStopMinDuration, err)
 	}
+	if err := validateBackend(c.Backend); err != nil {
+		return err
+	}
+	switch c.AutoStopEngine {
+	case audio.EngineEnergy, audio.EngineVAD:
+	default:
+		return fmt.Errorf("invalid auto-stop engine: %s (valid values: energy, vad)", c.AutoStopEngine)
+	}
+	if d, err := time.ParseDuration(c.PreRoll); err != nil || d < 0 {
+		return fmt.Errorf("invalid pre-roll '%v', must be a non-negative duration", c.PreRoll)
+	}
+	if d, err := time.ParseDuration(c.MaxWait); err != nil || d < 0 {
+		return fmt.Errorf("invalid max wait '%v', must be a non-negative duration", c.MaxWait)
+	}
+	if d, err := time.ParseDuration(c.TrimPad); err != nil || d < 0 {
+		return fmt.Errorf("invalid trim pad '%v', must be a non-negative duration", c.TrimPad)
+	}
+	if c.AutoStopHysteresis < 0 {
+		return fmt.Errorf("auto-stop hysteresis must be >= 0 dB, was '%v'", c.AutoStopHysteresis)
+	}
+	if c.AutoStopNoiseMargin < 0 {
+		return fmt.Errorf("auto-stop noise margin must be >= 0 dB, was '%v'", c.AutoStopNoiseMargin)
+	}
+	if err := c.Quality.validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
 // runCapture executes the audio capture logic.
-func runCapture(baseConfig *Config, config *CaptureConfig, outputFile string, duration time.Duration) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
+func runCapture(
+	baseConfig *Config,
+	config *CaptureConfig,
+	isSet func(string) bool,
+	outputFile string,
+	duration time.Duration,
+) (retErr error) {
 	logger := baseConfig.getLogger()
 	slog.SetDefault(logger)
 
-	lister, err := audio.NewDeviceLister(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to initialize device lister: %w", err)
-	}
-
-	devices := lister.ListDevices()
+	// The first interrupt stops the recording, which is still encoded
+	ctx, captureCtx, endCapture, stopInterrupts := handleInterrupts(context.Background(), logger)
+	defer stopInterrupts()
+	defer func() { retErr = interruptError(ctx, retErr) }()
 
-	// Resolve device
-	var selectedDevice audio.Device
-	if config.Device == "" {
-		selectedDevice, err = audio.GetDefaultSource(devices)
-		if err != nil {
-			return fmt.Errorf("failed to get default source device: %w", err)
-		}
-	} else {
-		selectedDevice, err = audio.GetDevice(config.Device, devices)
-		if err != nil {
-			return fmt.Errorf("device '%s' not found", config.Device)
-		}
+	selectedDevice, err := resolveDevice(ctx, config.Backend, config.Device)
+	if err != nil {
+		return err
 	}
 
 	// Parse and set sample rate if provided
@@ -92,13 +305,14 @@ func runCapture(baseConfig *Config, config *CaptureConfig, outputFile string, du
 		selectedDevice.SampleRate = config.SampleRate
 	}
 
-	// Parse auto-stop min duration if auto-stop is enabled
-	var minSilenceDuration time.Duration
-	if !config.NoAutoStop {
-		minSilenceDuration, err = time.ParseDuration(config.AutoStopMinDuration)
-		if err != nil {
-			return fmt.Errorf("invalid auto-stop min duration: %w", err)
-		}
+	// Use calibrated settings for the device unless overridden
+	if err := applyDeviceProfile(config, selectedDevice.Name, isSet, ""); err != nil {
+		return err
+	}
+
+	durations, err := parseSpeechDurations(config)
+	if err != nil {
+		return err
 	}
 
 	// Print individual fields to avoid JSON serialization issues
@@ -109,7 +323,7 @@ func runCapture(baseConfig *Config, config *CaptureConfig, outputFile string, du
 		"auto_stop_enabled", !config.NoAutoStop)
 
 	// Asynchronously read from the capture inputs into the Converter, which in
-	// turn writes to the FLAC output
+	// turn writes to the encoded output
 	var writer io.Writer
 	var file *os.File
 	if outputFile == "-" {
@@ -124,37 +338,73 @@ func runCapture(baseConfig *Config, config *CaptureConfig, outputFile string, du
 		defer func() { _ = file.Close() }()
 	}
 
+	pause := new(audio.PauseSwitch)
+	// Key controls would consume audio when it is read from stdin
+	stopPauseControls := watchPauseControls(ctx, logger, pause, !audio.IsStdinSource(config.Device))
+	defer stopPauseControls()
+
+	recorder, err := audio.NewRecorder(logger, audio.RecorderArgs{
+		Backend:  config.Backend,
+		Device:   selectedDevice,
+		Channels: config.Channels,
+		BitDepth: config.BitDepth,
+	})
+	if err != nil {
+		return err
+	}
+
 	pipeReader, pipeWriter := io.Pipe()
 
 	// Run LimitedCapture in a goroutine so it can write to the pipe concurrently with ConvertAudio reading
+	var stats audio.CaptureStats
+	captureDone := make(chan error, 1)
 	go func() {
-		err := audio.LimitedCapture(ctx, logger, selectedDevice, audio.LimitedCaptureArgs{
+		var err error
+		stats, err = audio.LimitedCapture(captureCtx, logger, recorder, audio.LimitedCaptureArgs{
 			EnableAutoStop:      !config.NoAutoStop,
+			AutoStopEngine:      config.AutoStopEngine,
 			AutoStopThreshold:   config.AutoStopThreshold,
-			AutoStopMinDuration: minSilenceDuration,
+			AutoStopMinDuration: durations.minSilence,
+			AutoStopHysteresis:  config.AutoStopHysteresis,
+			AutoStopNoiseMargin: config.AutoStopNoiseMargin,
+			WaitForSpeech:       config.WaitForSpeech,
+			PreRoll:             durations.preRoll,
+			MaxWait:             durations.maxWait,
+			TrimSilence:         config.TrimSilence,
+			TrimPad:             durations.trimPad,
+			Pause:               pause,
+			Meter:               meterOutput(config),
 			Duration:            duration,
-			Channels:            config.Channels,
-			BitDepth:            config.BitDepth,
 			Writer:              pipeWriter,
 		})
-		if err != nil {
-			slog.Error("Audio capture failed", "error", err, "device", selectedDevice)
-			// Note: error handling in goroutine - in a real implementation you might need to signal this back
-		}
+		endCapture()
+		captureDone <- err
 	}()
 
 	err = audio.ConvertAudio(ctx, logger, audio.ConvertAudioArgs{
 		Reader:       pipeReader,
 		Writer:       writer,
 		SourceFormat: "raw",
-		TargetFormat: "flac",
-		SampleRate:   selectedDevice.SampleRate,
-		Channels:     config.Channels,
-		BitDepth:     config.BitDepth,
+		TargetFormat: config.Format,
+		SampleRate:   recorder.Format().SampleRate,
+		Channels:     recorder.Format().Channels,
+		BitDepth:     recorder.Format().BitDepth,
+		Codec:        config.Codec,
+		Bitrate:      config.Bitrate,
+		Quality:      config.CodecQuality,
+		Backend:      config.Backend,
 	})
 	if err != nil {
 		return fmt.Errorf("audio conversion failed: %w", err)
 	}
+	if err := <-captureDone; err != nil {
+		slog.Error("Audio capture failed", "error", err, "device", selectedDevice)
+		return fmt.Errorf("audio capture failed: %w", err)
+	}
+
+	if err := checkQuality(&config.Quality, stats.Quality); err != nil {
+		return err
+	}
 
 	if baseConfig.Verbose {
 		slog.Debug("Audio capture completed successfully")
@@ -174,13 +424,66 @@ func NewCaptureCommand() *cli.Command {
 		Name:      "capture",
 		Usage:     "Capture audio from microphone to a file or stdout",
 		ArgsUsage: "OUTPUT_FILE",
-		Description: `Capture audio from the specified microphone device using Sox.
+		Description: `Capture audio from the specified microphone device. Audio is recorded with the
+--backend recorder, which defaults to sox, or ffmpeg when sox is not installed. Use --backend ffmpeg
+to record with ffmpeg when the installed sox lacks FLAC or driver support.
+On Linux desktops running PipeWire, --backend pipewire records with pw-record directly; its nodes
+can be selected by name, id or description.
+On machines with only ALSA, such as headless build boxes without a sound server, the default
+--backend auto records with arecord instead; --backend alsa selects it explicitly.
+
+Without a microphone, --device can name a source instead, which works with any backend:
+  file:PATH        replays a WAV file at real-time pace, keeping its rate and channels
+  stdin (or -)     reads raw signed little-endian PCM at --rate (default 48000), --channels and --bit-depth
+  synth:PATTERN    generates comma-separated KIND[=VALUE][/DURATION] steps at real-time pace, where
+                   KIND is tone (VALUE in Hz, default 440), noise (VALUE in dBFS, default -50) or
+                   silence. Only the last step may omit its duration, in which case it never ends.
+The Enter key does not pause a capture from stdin.
 
 The OUTPUT_FILE is a required positional argument that specifies where to send the audio output.
 Use "-" to output to stdout.
-Output format is always FLAC.
+Output format is FLAC by default, or inferred from the OUTPUT_FILE extension (.flac, .wav, .ogg,
+.opus, .mp3, .raw). Use --format to select the container and --codec the codec in it:
+  flac   flac
+  wav    pcm
+  ogg    vorbis (default), opus or flac
+  mp3    mp3
+  raw    pcm, headerless signed little-endian samples
+Lossy codecs take a --bitrate in kbit/s and a --codec-quality from 1 (smallest) to 10 (best);
+for Opus the quality selects the encoder complexity. Other pairs are rejected.
+WAV, FLAC and raw output are encoded in-process; when writing to stdout the WAV sizes are set
+to 0xFFFFFFFF (unknown length) and the FLAC sample count and MD5 signature are left unset.
+Ogg and MP3 are encoded with sox, or with ffmpeg for Opus, Ogg/FLAC and Vorbis with a bitrate.
 
 By default, capture stops automatically when silence is detected. Use --no-auto-stop to disable this.
+Speech is detected from the RMS level of 20ms windows. It starts above --auto-stop-threshold and
+ends once the level has stayed --auto-stop-hysteresis dB below it for --auto-stop-min-duration.
+The threshold is raised to --auto-stop-noise-margin dB above the estimated background noise, which
+is the quietest level of the last 1.5 seconds and rises slowly, so steady noise such as HVAC
+above the threshold does not keep the capture running.
+Use --wait-for-speech to start recording only once speech is detected. The last --pre-roll of
+audio before the speech is kept so that the first syllable is not lost. If no speech starts
+within --max-wait, the command fails with exit code 3.
+Use --trim-silence to remove silence before the first and after the last speech, keeping
+--trim-pad of silence on either side. Pauses longer than 30 seconds between speech are shortened,
+and without any speech only the last seconds of the capture are kept.
+Auto-stop settings saved by the calibrate command are used for the device unless the flags are given.
+Use --auto-stop-engine vad to detect speech from its spectrum instead, which is more robust
+against keyboard and ventilation noise. The VAD ignores audio quieter than the silence level.
+
+After capture, the audio is checked for low input level, clipping, DC offset and a poor
+signal-to-noise ratio, and warnings are printed to stderr. Use --quality-strict to fail instead.
+
+While capturing, a live level meter with the elapsed time and the silence countdown to auto-stop
+is shown on stderr. It is disabled when stderr is not a terminal, or with --no-meter.
+
+Send SIGUSR1 to pause the capture and SIGUSR2 to resume it. When stdin is a terminal, pressing
+Enter toggles between the two. Audio captured while paused is discarded and does not count
+towards auto-stop, so the output only contains the active sections.
+
+Press Ctrl-C (or send SIGTERM) to stop recording early; the audio captured so far is still
+written. A second interrupt, or the first once recording has ended, aborts the command with
+exit code 130.
 
 Examples:
   # Output to file (with auto-stop)
@@ -189,8 +492,14 @@ Examples:
   # Output to stdout
   sttrouter capture -
 
+  # Capture small Opus files for speech
+  sttrouter capture --bitrate 24 recording.opus
+
   # Capture for 10 seconds without auto-stop
-  sttrouter capture --duration 10s --no-auto-stop recording.flac`,
+  sttrouter capture --duration 10s --no-auto-stop recording.flac
+
+  # Check that auto-stop ends a capture after 2s of tone and 3s of silence
+  sttrouter capture --device "synth:tone/2s,silence/3s" test.flac`,
 		Flags: flags,
 		Action: func(c *cli.Context) error {
 			if c.NArg() != 1 {
@@ -198,17 +507,21 @@ Examples:
 			}
 
 			outputFile := c.Args().Get(0)
+			inferFormat(&captureConfig, c.IsSet, "", outputFile)
 
 			if err := baseConfig.validate(); err != nil {
 				return err
 			}
+			if err := captureConfig.validate(); err != nil {
+				return err
+			}
 
 			duration, err := time.ParseDuration(captureConfig.Duration)
 			if err != nil {
 				return fmt.Errorf("invalid duration: %w", err)
 			}
 
-			return runCapture(&baseConfig, &captureConfig, outputFile, duration)
+			return runCapture(&baseConfig, &captureConfig, c.IsSet, outputFile, duration)
 		},
 	}
 }
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/sebnyberg/sttrouter/audio"
+	"github.com/sebnyberg/sttrouter/openaix"
+)
+
+// ErrNoSpeech indicates that no speech was detected in the captured audio
+var ErrNoSpeech = errors.New("no speech detected")
+
+// ErrInterrupted indicates that the command was aborted by a second interrupt signal
+var ErrInterrupted = errors.New("interrupted")
+
+// Process exit codes for errors returned by commands
+const (
+	ExitCodeError                = 1
+	ExitCodeNoSpeech             = 3
+	ExitCodeSuspectTranscription = 4
+	ExitCodeInterrupted          = 130 // 128 + SIGINT, as set by shells
+)
+
+// ExitCode returns the process exit code for an error returned by a command
+func ExitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInterrupted):
+		return ExitCodeInterrupted
+	case errors.Is(err, ErrNoSpeech), errors.Is(err, audio.ErrSpeechTimeout):
+		return ExitCodeNoSpeech
+	case errors.Is(err, openaix.ErrSuspectTranscription):
+		return ExitCodeSuspectTranscription
+	default:
+		return ExitCodeError
+	}
+}
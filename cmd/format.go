@@ -1,6 +1,13 @@
 package cmd
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sebnyberg/sttrouter/openaix"
+)
 
 // Output format constants
 const (
@@ -36,3 +43,47 @@ func formatOutput(format string, data any) (string, error) {
 		return "", fmt.Errorf("unsupported format: %s", format)
 	}
 }
+
+// formatSpeakerTranscript renders transcript segments as one line per speaker
+// turn, e.g. "[Speaker A 00:01:23] text". Consecutive segments from the same
+// speaker are merged. Known speaker names are used as labels as-is.
+func formatSpeakerTranscript(t *openaix.TranscriptionResponse, knownSpeakerNames []string) string {
+	if len(t.Segments) == 0 {
+		return t.Text
+	}
+
+	var lines []string
+	var turn strings.Builder
+	for i, seg := range t.Segments {
+		if i > 0 && seg.Speaker == t.Segments[i-1].Speaker && seg.Speaker != "" {
+			turn.WriteString(" ")
+			turn.WriteString(strings.TrimSpace(seg.Text))
+			continue
+		}
+		if turn.Len() > 0 {
+			lines = append(lines, turn.String())
+			turn.Reset()
+		}
+
+		label := formatTimestamp(seg.Start)
+		switch {
+		case seg.Speaker == "":
+		case slices.Contains(knownSpeakerNames, seg.Speaker):
+			label = seg.Speaker + " " + label
+		default:
+			label = "Speaker " + seg.Speaker + " " + label
+		}
+		fmt.Fprintf(&turn, "[%s] %s", label, strings.TrimSpace(seg.Text))
+	}
+	lines = append(lines, turn.String())
+	return strings.Join(lines, "\n")
+}
+
+// formatTimestamp formats seconds as HH:MM:SS
+func formatTimestamp(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
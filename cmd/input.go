@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sebnyberg/sttrouter/audio"
+)
+
+// providerExtensions maps the containers that the provider reads to the file
+// extensions it accepts for them
+var providerExtensions = map[string][]string{
+	audio.ContainerWAV:  {".wav"},
+	audio.ContainerFLAC: {".flac"},
+	audio.ContainerMP3:  {".mp3", ".mpga", ".mpeg"},
+	audio.ContainerOgg:  {".ogg"},
+	audio.ContainerMP4:  {".m4a", ".mp4"},
+	audio.ContainerWebM: {".webm"},
+}
+
+// inputDecodeBitDepth is the bit depth of inputs decoded with sox or ffmpeg
+const inputDecodeBitDepth = 16
+
+// needsConversion reports whether an input file is converted before it is
+// uploaded with the upload profile. Lossy audio the provider reads is uploaded
+// as is, since encoding it again loses quality and saves little.
+func needsConversion(profile, path string, info audio.MediaInfo) bool {
+	extensions, ok := providerExtensions[info.Container]
+	switch {
+	case !ok || info.Video:
+		return true
+	case !slices.Contains(extensions, strings.ToLower(filepath.Ext(path))):
+		// The provider detects the format from the file name
+		return true
+	case info.Container == audio.ContainerWAV:
+		return true
+	case info.Container == audio.ContainerFLAC:
+		return uploadFormat(profile, info.Format) != info.Format
+	default:
+		return false
+	}
+}
+
+// normalizeInput converts an input file to FLAC in the upload profile format,
+// writing it to w. WAV files are converted in-process, other files are
+// decoded with sox or ffmpeg first.
+func normalizeInput(
+	ctx context.Context,
+	logger *slog.Logger,
+	config *TranscribeConfig,
+	path string,
+	info audio.MediaInfo,
+	w io.Writer,
+) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	// The PCM converter reads signed integer samples
+	if info.Codec == audio.CodecPCM && info.Format.SampleFormat().Encoding == audio.SignedInt {
+		wr, err := audio.NewWavReader(f)
+		if err != nil {
+			return err
+		}
+		return encodeInput(ctx, logger, config, wr, wr.Format, w)
+	}
+
+	pr, pw := io.Pipe()
+	decoded := make(chan error, 1)
+	go func() {
+		err := audio.ConvertAudio(ctx, logger, audio.ConvertAudioArgs{
+			Writer:       pw,
+			SourcePath:   path,
+			SourceFormat: info.Container,
+			TargetFormat: audio.ContainerWAV,
+			Codec:        audio.CodecPCM,
+			BitDepth:     inputDecodeBitDepth,
+			Backend:      config.Capture.Backend,
+		})
+		_ = pw.CloseWithError(err)
+		decoded <- err
+	}()
+	wr, err := audio.NewWavReader(pr)
+	if err == nil {
+		err = encodeInput(ctx, logger, config, wr, wr.Format, w)
+	}
+	// Stop the decoder if encoding failed. Reads fail with the decoding
+	// error, which is reported without the read context.
+	_ = pr.CloseWithError(err)
+	if decodeErr := <-decoded; errors.Is(err, decodeErr) {
+		return decodeErr
+	}
+	return err
+}
+
+// encodeInput encodes PCM in the from format to FLAC in the upload profile format
+func encodeInput(
+	ctx context.Context,
+	logger *slog.Logger,
+	config *TranscribeConfig,
+	r io.Reader,
+	from audio.WavFormat,
+	w io.Writer,
+) error {
+	to := uploadFormat(config.UploadProfile, from)
+	// FLAC holds at most 24 bits per sample
+	to.BitDepth = min(to.BitDepth, 24)
+	slog.Debug("Converting input", "from", from, "to", to)
+
+	e, err := startEncoder(ctx, logger, w, from, to, &CaptureConfig{
+		Format:  audio.ContainerFLAC,
+		Backend: config.Capture.Backend,
+	})
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(e, r)
+	closeErr := e.Close()
+	if err := e.wait(); err != nil {
+		return err
+	}
+	return errors.Join(copyErr, closeErr)
+}
+
+// prepareInput checks the format of an input file and returns the path of the
+// file to upload, which is a converted temporary file unless the provider can
+// read the input as is
+func prepareInput(ctx context.Context, logger *slog.Logger, config *TranscribeConfig, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open input file: %w", err)
+	}
+	info, err := audio.ProbeMedia(f)
+	_ = f.Close()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	slog.Debug("Probed input file", "path", path, "container", info.Container, "codec", info.Codec,
+		"format", info.Format, "video", info.Video)
+
+	if !needsConversion(config.UploadProfile, path, info) {
+		fmt.Println("Using provided audio file for transcription")
+		return path, nil
+	}
+
+	tempFile, err := os.CreateTemp("", "sttrouter-input-*.flac")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	slog.Debug("tempfile created", "path", tempFile.Name())
+	if config.Debug {
+		fmt.Printf("Debug: temp file created at %s\n", tempFile.Name())
+	}
+	fmt.Printf("Converting %s input for transcription\n", info.Container)
+	err = normalizeInput(ctx, logger, config, path, info, tempFile)
+	if closeErr := tempFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close the temporary audio file, %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return "", fmt.Errorf("failed to convert %s: %w", path, err)
+	}
+	return tempFile.Name(), nil
+}
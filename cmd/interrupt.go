@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleInterrupts installs handlers for SIGINT and SIGTERM. The first signal
+// cancels the returned capture context, which ends the recording cleanly while
+// the command goes on to encode and transcribe it. The second signal cancels
+// ctx with ErrInterrupted, aborting whatever is in flight. Once endCapture has
+// been called, the first signal aborts. The returned stop function removes
+// the handlers.
+func handleInterrupts(
+	parent context.Context,
+	logger *slog.Logger,
+) (ctx, captureCtx context.Context, endCapture, stop func()) {
+	ctx, abort := context.WithCancelCause(parent)
+	captureCtx, stopCapture := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-captureCtx.Done():
+			// The capture has ended, so there is nothing to stop
+		case sig := <-signals:
+			logger.Info("stopping capture, interrupt again to abort", "signal", sig)
+			stopCapture()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			logger.Info("aborting", "signal", sig)
+			abort(ErrInterrupted)
+		}
+	}()
+
+	return ctx, captureCtx, stopCapture, func() {
+		signal.Stop(signals)
+		stopCapture()
+		abort(nil)
+	}
+}
+
+// interruptError returns err marked with ErrInterrupted if ctx was aborted by
+// a second signal, see handleInterrupts
+func interruptError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(context.Cause(ctx), ErrInterrupted) || errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrInterrupted, err)
+}
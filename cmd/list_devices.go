@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -16,6 +17,8 @@ import (
 type ListDevicesConfig struct {
 	// OutputFormat specifies the output format for the device list
 	OutputFormat string `name:"o" env:"OUTPUT_FORMAT" value:"json" usage:"Output format (json, table, csv)"`
+	// Backend selects whose devices are listed
+	Backend string `name:"backend" value:"auto" usage:"Recorder backend (auto, sox, ffmpeg, pipewire, alsa)"`
 }
 
 // listDevicesResult is used for output formatting
@@ -74,6 +77,9 @@ func (r *listDevicesResult) ToCSV() string {
 
 // validate validates the list-devices configuration.
 func (c *ListDevicesConfig) validate() error {
+	if err := validateBackend(c.Backend); err != nil {
+		return err
+	}
 	switch c.OutputFormat {
 	case textOutputFormatJSON, textOutputFormatTable, textOutputFormatCSV:
 		return nil
@@ -93,10 +99,11 @@ func runListDevices(baseConfig *Config, config *ListDevicesConfig) error {
 	logger := baseConfig.getLogger()
 	slog.SetDefault(logger)
 
-	devices, err := audio.ListDevices()
+	lister, err := audio.NewBackendDeviceLister(context.Background(), config.Backend)
 	if err != nil {
 		return err
 	}
+	devices := lister.ListDevices()
 
 	result := &listDevicesResult{Devices: devices}
 
@@ -127,7 +134,9 @@ func NewListDevicesCommand() *cli.Command {
 		Description: `List available audio devices for recording and playback.
 
 This command enumerates audio devices using platform-specific tools (system_profiler on macOS, pactl on Linux),
-processes the information, and outputs devices in lexicographical order with their capabilities.`,
+processes the information, and outputs devices in lexicographical order with their capabilities.
+With --backend pipewire, PipeWire nodes are listed from pw-dump instead, including their id and description.
+On Linux without pactl, or with --backend alsa, ALSA capture devices are listed from arecord -L or /proc/asound.`,
 		Flags: flags,
 		Action: func(c *cli.Context) error {
 			if err := baseConfig.validate(); err != nil {
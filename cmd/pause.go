@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sebnyberg/sttrouter/audio"
+)
+
+// watchPauseControls pauses the capture on SIGUSR1 and resumes it on SIGUSR2.
+// When keys is set and stdin is a terminal, pressing Enter toggles between
+// the two. The returned function stops watching.
+func watchPauseControls(ctx context.Context, logger *slog.Logger, sw *audio.PauseSwitch, keys bool) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	report := func(paused bool) {
+		if paused {
+			logger.Info("capture paused")
+		} else {
+			logger.Info("capture resumed")
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					sw.Pause()
+				} else {
+					sw.Resume()
+				}
+				report(sw.Paused())
+			}
+		}
+	}()
+
+	// The reader cannot be interrupted, so it is left blocked on stdin once
+	// the capture is done and ignores any further input
+	if keys && isTerminal(os.Stdin) {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if ctx.Err() != nil {
+					return
+				}
+				report(sw.Toggle())
+			}
+		}()
+	}
+
+	return func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
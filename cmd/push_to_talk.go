@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/sebnyberg/sttrouter/audio"
+	"golang.org/x/term"
+)
+
+const (
+	// keyCtrlC is the byte sent by Ctrl-C when the terminal is in raw mode
+	keyCtrlC = 0x03
+	// minRepeatTimeout is the shortest time without key repeats that counts as
+	// a release, to allow for jitter over SSH
+	minRepeatTimeout = 100 * time.Millisecond
+)
+
+// PushToTalkConfig holds push-to-talk configuration.
+type PushToTalkConfig struct {
+	// Key is the key that controls recording
+	Key string `name:"key" value:"space" usage:"Push-to-talk key (space, enter, tab or a single character)"`
+	// Mode is hold to record while the key is held, or toggle to start and stop on presses
+	Mode string `name:"mode" value:"hold" usage:"Push-to-talk mode (hold, toggle)"`
+	// RepeatDelay is how long to wait for the first key repeat before a held key counts as released
+	RepeatDelay string `name:"repeat-delay" value:"700ms" usage:"Wait for key repeat before a key counts as released"`
+}
+
+func (c *PushToTalkConfig) validate() error {
+	if _, err := parseKey(c.Key); err != nil {
+		return err
+	}
+	switch c.Mode {
+	case "hold", "toggle":
+		// Valid modes
+	default:
+		return fmt.Errorf("invalid push-to-talk mode: %s (valid values: hold, toggle)", c.Mode)
+	}
+	if d, err := time.ParseDuration(c.RepeatDelay); err != nil || d <= 0 {
+		return fmt.Errorf("invalid push-to-talk repeat delay '%v', must be a positive duration", c.RepeatDelay)
+	}
+	return nil
+}
+
+// parseKey returns the byte a terminal in raw mode sends for the named key
+func parseKey(name string) (byte, error) {
+	switch name {
+	case "space":
+		return ' ', nil
+	case "enter":
+		return '\r', nil
+	case "tab":
+		return '\t', nil
+	}
+	if len(name) == 1 && name[0] > ' ' && name[0] < 0x7f {
+		return name[0], nil
+	}
+	return 0, fmt.Errorf("invalid push-to-talk key '%v', must be space, enter, tab or a single character", name)
+}
+
+// keyLabel returns the name of a key as shown to the user
+func keyLabel(name string) string {
+	if len(name) == 1 {
+		return name
+	}
+	return fmt.Sprintf("%c%s", name[0]-'a'+'A', name[1:])
+}
+
+// startPushToTalk puts the terminal in raw mode and drives the pause switch of
+// a capture from key presses. Terminals do not report key releases, so in hold
+// mode the key counts as released when its key repeats stop. Once the speaker
+// is done, stopCapture is called. Ctrl-C is forwarded as SIGINT, see
+// handleInterrupts. The returned function restores the terminal.
+func startPushToTalk(
+	ctx context.Context,
+	config *PushToTalkConfig,
+	sw *audio.PauseSwitch,
+	stopCapture func(),
+) (restore func(), err error) {
+	key, err := parseKey(config.Key)
+	if err != nil {
+		return nil, err
+	}
+	repeatDelay, err := time.ParseDuration(config.RepeatDelay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push-to-talk repeat delay: %w", err)
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("push-to-talk requires stdin to be a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put terminal in raw mode: %w", err)
+	}
+
+	// The reader cannot be interrupted, so it is left blocked on stdin once
+	// the capture is done and ignores any further input
+	ctx, cancel := context.WithCancel(ctx)
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, b := range buf[:n] {
+				select {
+				case keys <- b:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	label := keyLabel(config.Key)
+	status := func(format string, args ...any) {
+		// Raw mode does not translate newlines, so the status stays on one line
+		_, _ = fmt.Fprintf(os.Stderr, "\r\033[K"+format, args...)
+	}
+	if config.Mode == "toggle" {
+		status("Press %s to start recording", label)
+	} else {
+		status("Hold %s to talk", label)
+	}
+
+	go func() {
+		defer cancel()
+		pushToTalkLoop(ctx, keys, key, config.Mode == "toggle", repeatDelay, sw, status)
+		stopCapture()
+	}()
+
+	return func() {
+		cancel()
+		_ = term.Restore(fd, state)
+		_, _ = io.WriteString(os.Stderr, "\r\033[K")
+	}, nil
+}
+
+// pushToTalkLoop handles key presses until recording is done or ctx is cancelled
+func pushToTalkLoop(
+	ctx context.Context,
+	keys <-chan byte,
+	key byte,
+	toggle bool,
+	repeatDelay time.Duration,
+	sw *audio.PauseSwitch,
+	status func(format string, args ...any),
+) {
+	// Before the first repeat the key is held for at least the terminal's
+	// repeat delay. After that, it is released once repeats stop arriving at
+	// the observed rate.
+	release := time.NewTimer(repeatDelay)
+	release.Stop()
+	recording := false
+	var lastPress time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-release.C:
+			sw.Pause()
+			return
+		case b := <-keys:
+			if b == keyCtrlC {
+				_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+				continue
+			}
+			if b != key {
+				continue
+			}
+			now := time.Now()
+			switch {
+			case !recording:
+				recording = true
+				sw.Resume()
+				if toggle {
+					status("Recording, press again to stop")
+				} else {
+					status("Recording")
+					release.Reset(repeatDelay)
+				}
+			case toggle:
+				// Key repeats from holding the key are not presses
+				if now.Sub(lastPress) < repeatDelay {
+					lastPress = now
+					continue
+				}
+				sw.Pause()
+				return
+			default:
+				release.Reset(min(max(3*now.Sub(lastPress), minRepeatTimeout), repeatDelay))
+			}
+			lastPress = now
+		}
+	}
+}
@@ -3,10 +3,12 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/sebnyberg/flagtags"
@@ -24,28 +26,101 @@ type OpenAIConfig struct {
 	BaseURL string `name:"base-url"`
 }
 
+// BreakerConfig holds circuit breaker configuration for provider endpoints.
+type BreakerConfig struct {
+	// Disabled disables circuit breaking
+	Disabled bool `name:"disabled" usage:"Disable the circuit breaker for provider endpoints"`
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int `name:"failure-threshold" value:"3" usage:"Failures before the circuit opens"`
+	// Cooldown is the time the circuit stays open before probing the endpoint again
+	Cooldown string `name:"cooldown" value:"1m" usage:"Time the circuit stays open before probing again"`
+	// StateFile is where breaker state is persisted between invocations
+	StateFile string `name:"state-file" usage:"Circuit breaker state file (defaults to the user cache directory)"`
+}
+
+// GuardConfig holds configuration for detecting hallucinated transcriptions.
+type GuardConfig struct {
+	// Action is the action taken for suspect transcriptions (drop, flag, none)
+	Action string `name:"action" value:"drop" usage:"Action for suspect transcriptions (drop, flag, none)"`
+	// MaxNoSpeechProb is the highest accepted average no-speech probability
+	MaxNoSpeechProb float64 `name:"max-no-speech-prob" value:"0.6" usage:"Highest accepted no-speech probability"`
+	// MaxCompressionRatio is the highest accepted text compression ratio
+	MaxCompressionRatio float64 `name:"max-compression-ratio" value:"2.4" usage:"Highest accepted compression ratio"`
+	// MaxWordsPerSecond is the highest accepted number of words per second of detected speech
+	MaxWordsPerSecond float64 `name:"max-words-per-second" value:"6" usage:"Highest accepted words per second of speech"`
+	// Blocklist is a comma-separated list of known hallucination phrases
+	Blocklist string `name:"blocklist" usage:"Comma-separated hallucination phrases (defaults to a built-in list)"`
+}
+
+func (c *GuardConfig) validate() error {
+	switch c.Action {
+	case "drop", "flag", "none":
+		// Valid actions
+	default:
+		return fmt.Errorf("invalid guard action: %s (valid values: drop, flag, none)", c.Action)
+	}
+	if c.MaxNoSpeechProb < 0 || c.MaxNoSpeechProb > 1 {
+		return fmt.Errorf("guard max no-speech probability must be in the interval [0,1], was '%v'", c.MaxNoSpeechProb)
+	}
+	if c.MaxCompressionRatio < 0 {
+		return fmt.Errorf("guard max compression ratio must be >= 0, was '%v'", c.MaxCompressionRatio)
+	}
+	if c.MaxWordsPerSecond < 0 {
+		return fmt.Errorf("guard max words per second must be >= 0, was '%v'", c.MaxWordsPerSecond)
+	}
+	return nil
+}
+
+func (c *BreakerConfig) validate() error {
+	if c.FailureThreshold <= 0 {
+		return fmt.Errorf("breaker failure threshold must be greater than 0, was '%v'", c.FailureThreshold)
+	}
+	// The cooldown is parsed when the endpoints are created, before capture starts
+	return nil
+}
+
 // TranscribeConfig holds transcribe specific configuration flags.
 type TranscribeConfig struct {
 	// Model specifies the GPT-4o model to use
 	Model string `name:"model" value:"gpt-4o-transcribe" usage:"Model to use for transcription"`
 	// Language specifies the language code
 	Language string `name:"language" value:"en" usage:"Language code (e.g., 'en', 'es')"`
-	// ResponseFormat specifies the response format (json, text, srt, verbose_json, vtt)
-	ResponseFormat string `name:"response-format" value:"text" usage:"Response format (json,text,srt,verbose_json,vtt)"`
+	// ResponseFormat specifies the response format (json, text, srt, verbose_json, vtt, diarized_json)
+	ResponseFormat string `name:"response-format" value:"text" usage:"Response format (e.g. json, text, diarized_json)"`
 	// Temperature specifies the sampling temperature (0.0 to 1.0)
 	Temperature float64 `name:"temperature" value:"0" usage:"Sampling temperature (0.0 to 1.0)"`
 	// OpenAI configuration
 	OpenAI OpenAIConfig `name:"openai"`
+	// Fallback provider used when the primary endpoint is unavailable
+	Fallback OpenAIConfig `name:"fallback"`
+	// Circuit breaker configuration
+	Breaker BreakerConfig `name:"breaker"`
+	// Hallucination guard configuration
+	Guard GuardConfig `name:"guard"`
 	// Additional query parameters for the API request
 	AdditionalQueryParams string `name:"query-params" value:"api-version=2025-03-01-preview" usage:"Query params"`
 	// Configuration for audio capture
 	Capture CaptureConfig
+	// PushToTalk records only while a key is held, or between two presses
+	PushToTalk bool `name:"push-to-talk" usage:"Record while a key is held instead of stopping on silence"`
+	// Push-to-talk configuration
+	PushToTalkOptions PushToTalkConfig `name:"push-to-talk"`
+	// MinSpeechDuration is the minimum captured speech required to call the provider
+	MinSpeechDuration string `name:"min-speech-duration" value:"300ms" usage:"Minimum speech required to transcribe"`
 	// NoClipboard disables copying transcription result to clipboard
 	NoClipboard bool `name:"no-clipboard" usage:"Disable copying transcription result to clipboard"`
 	// NoCapture disables audio capture and uses a provided file instead
 	NoCapture bool `name:"no-capture" usage:"Disable audio capture and use provided file for transcription"`
-	// OutputFormat specifies the output format (none, text)
-	OutputFormat string `name:"output-format" value:"text" usage:"Output format (none, text)"`
+	// UploadProfile selects the format of the audio sent for transcription
+	UploadProfile string `name:"upload-profile" value:"speech" usage:"Uploaded audio (speech: 16kHz mono, full)"`
+	// Archive saves the captured audio at full quality
+	Archive string `name:"archive" usage:"Also save the captured audio at full quality to this file"`
+	// KnownSpeakerNames is a comma-separated list of speaker names for diarization
+	KnownSpeakerNames string `name:"known-speaker-names" usage:"Comma-separated speaker names for diarization"`
+	// KnownSpeakerReferences is a comma-separated list of reference clips, one per known speaker name
+	KnownSpeakerReferences string `name:"known-speaker-references" usage:"Reference clips, one per speaker name"`
+	// OutputFormat specifies the output format (none, text, speakers)
+	OutputFormat string `name:"output-format" value:"text" usage:"Output format (none, text, speakers)"`
 	// Debug enables debug mode, keeping temp files and printing their locations
 	Debug bool `name:"debug" usage:"Enable debug mode (keeps temp files and prints locations)"`
 }
@@ -57,45 +132,132 @@ func (c *TranscribeConfig) validate() error {
 			return fmt.Errorf("capture config validation err, %w", err)
 		}
 	}
+	if c.PushToTalk {
+		if c.NoCapture {
+			return fmt.Errorf("push-to-talk cannot be combined with --no-capture")
+		}
+		if c.Capture.WaitForSpeech {
+			return fmt.Errorf("push-to-talk cannot be combined with --capture-wait-for-speech")
+		}
+		if audio.IsStdinSource(c.Capture.Device) {
+			return fmt.Errorf("push-to-talk reads keys from stdin and cannot be combined with --capture-device stdin")
+		}
+		if err := c.PushToTalkOptions.validate(); err != nil {
+			return err
+		}
+	}
+	switch c.UploadProfile {
+	case uploadProfileSpeech, uploadProfileFull:
+		// Valid upload profiles
+	default:
+		return fmt.Errorf("invalid upload profile: %s (valid values: speech, full)", c.UploadProfile)
+	}
+	if c.Archive != "" && c.NoCapture {
+		return fmt.Errorf("--archive cannot be combined with --no-capture")
+	}
+	if c.Archive != "" {
+		archive := c.archiveConfig()
+		codec, err := audio.ResolveCodec(archive.Format, archive.Codec)
+		if err != nil {
+			return fmt.Errorf("invalid archive codec: %w", err)
+		}
+		if codec == audio.CodecFLAC && archive.BitDepth > 24 {
+			return fmt.Errorf("flac archives support bit depths up to 24, was '%v'", archive.BitDepth)
+		}
+	}
+	if !c.NoCapture && c.Capture.Format == audio.ContainerRaw {
+		return fmt.Errorf("raw audio cannot be transcribed, use another --capture-format")
+	}
 	if c.OpenAI.APIKey == "" {
 		return fmt.Errorf("API key is required (use --openai-api-key or set OPENAI_API_KEY environment variable)")
 	}
+	if !c.Breaker.Disabled {
+		if err := c.Breaker.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Guard.validate(); err != nil {
+		return err
+	}
+	if _, err := time.ParseDuration(c.MinSpeechDuration); err != nil {
+		return fmt.Errorf("invalid min speech duration '%v', %w", c.MinSpeechDuration, err)
+	}
 	switch c.OutputFormat {
 	case "none", "text":
 		// Valid output formats
+	case "speakers":
+		// Speaker labels and timestamps are only available in segmented responses
+		if c.ResponseFormat != "diarized_json" && c.ResponseFormat != "verbose_json" {
+			return fmt.Errorf(
+				"output format speakers requires response format diarized_json or verbose_json, was '%v'",
+				c.ResponseFormat,
+			)
+		}
 	default:
-		return fmt.Errorf("invalid output format: %s (valid values: none, text)", c.OutputFormat)
+		return fmt.Errorf("invalid output format: %s (valid values: none, text, speakers)", c.OutputFormat)
+	}
+	names := splitList(c.KnownSpeakerNames)
+	refs := splitList(c.KnownSpeakerReferences)
+	if len(names) != len(refs) {
+		return fmt.Errorf(
+			"known speaker names and references must have the same length, was %v and %v",
+			len(names),
+			len(refs),
+		)
 	}
 	return nil
 }
 
-// runCaptureToWriter captures audio and writes it directly to the specified temp file
-func runCaptureToWriter(baseConfig *Config, config *TranscribeConfig, resultsWriter io.Writer) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
 
-	logger := baseConfig.getLogger()
-	slog.SetDefault(logger)
+// isDiarizationModel reports whether the model produces speaker-labeled output
+func isDiarizationModel(model string) bool {
+	return strings.Contains(model, "diarize")
+}
 
-	lister, err := audio.NewDeviceLister(ctx)
-	if err != nil {
-		panic(fmt.Errorf("failed to initialize device lister: %w", err))
+// archiveConfig returns the capture config used to encode the archive. Its
+// format and codec are inferred from the archive extension, falling back to
+// the capture format, so that the upload keeps --capture-format.
+func (c *TranscribeConfig) archiveConfig() *CaptureConfig {
+	archive := c.Capture
+	container, codec, ok := audio.FormatFromPath(c.Archive)
+	if !ok || container == archive.Format {
+		return &archive
+	}
+	archive.Format, archive.Codec = container, codec
+	// The lossy settings of the capture format do not apply to another codec
+	if resolved, err := audio.ResolveCodec(container, codec); err != nil || !audio.IsLossyCodec(resolved) {
+		archive.Bitrate, archive.CodecQuality = 0, 0
 	}
+	return &archive
+}
 
-	devices := lister.ListDevices()
+// runCaptureToWriter captures audio until captureCtx is cancelled or auto-stop
+// ends the capture. The audio is written to resultsWriter in the upload
+// profile format, and at full quality to archiveWriter unless it is nil.
+func runCaptureToWriter(
+	ctx, captureCtx context.Context,
+	baseConfig *Config,
+	config *TranscribeConfig,
+	isSet func(string) bool,
+	resultsWriter io.Writer,
+	archiveWriter io.Writer,
+) (audio.CaptureStats, error) {
+	logger := baseConfig.getLogger()
+	slog.SetDefault(logger)
 
-	// Resolve device
-	var selectedDevice audio.Device
-	if config.Capture.Device == "" {
-		selectedDevice, err = audio.GetDefaultSource(devices)
-		if err != nil {
-			panic(fmt.Errorf("failed to get default source device: %w", err))
-		}
-	} else {
-		selectedDevice, err = audio.GetDevice(config.Capture.Device, devices)
-		if err != nil {
-			panic(fmt.Errorf("device '%s' not found", config.Capture.Device))
-		}
+	selectedDevice, err := resolveDevice(ctx, config.Capture.Backend, config.Capture.Device)
+	if err != nil {
+		return audio.CaptureStats{}, err
 	}
 
 	// Parse and set sample rate if provided
@@ -103,13 +265,14 @@ func runCaptureToWriter(baseConfig *Config, config *TranscribeConfig, resultsWri
 		selectedDevice.SampleRate = config.Capture.SampleRate
 	}
 
-	// Parse auto-stop min duration if auto-stop is enabled
-	var minSilenceDuration time.Duration
-	if !config.Capture.NoAutoStop {
-		minSilenceDuration, err = time.ParseDuration(config.Capture.AutoStopMinDuration)
-		if err != nil {
-			panic(fmt.Errorf("invalid auto-stop min duration: %w", err))
-		}
+	// Use calibrated settings for the device unless overridden
+	if err := applyDeviceProfile(&config.Capture, selectedDevice.Name, isSet, "capture-"); err != nil {
+		return audio.CaptureStats{}, err
+	}
+
+	durations, err := parseSpeechDurations(&config.Capture)
+	if err != nil {
+		return audio.CaptureStats{}, err
 	}
 
 	// Parse capture duration
@@ -124,56 +287,220 @@ func runCaptureToWriter(baseConfig *Config, config *TranscribeConfig, resultsWri
 		"duration", duration,
 		"auto_stop_enabled", !config.Capture.NoAutoStop)
 
-	pipeReader, pipeWriter := io.Pipe()
-
-	// Run LimitedCapture in a goroutine so it can write to the pipe concurrently with ConvertAudio reading
-	go func() {
-		err = audio.LimitedCapture(ctx, logger, selectedDevice, audio.LimitedCaptureArgs{
-			EnableAutoStop:      !config.Capture.NoAutoStop,
-			AutoStopThreshold:   config.Capture.AutoStopThreshold,
-			AutoStopMinDuration: minSilenceDuration,
-			Duration:            duration,
-			Channels:            config.Capture.Channels,
-			BitDepth:            config.Capture.BitDepth,
-			Writer:              pipeWriter,
-		})
+	// In push-to-talk mode the capture runs paused from the start, so that
+	// nothing is lost to starting sox when the key is pressed
+	pause := new(audio.PauseSwitch)
+	if config.PushToTalk {
+		pause.Pause()
+		var stopCapture context.CancelFunc
+		captureCtx, stopCapture = context.WithCancel(captureCtx)
+		defer stopCapture()
+		restoreTerminal, err := startPushToTalk(ctx, &config.PushToTalkOptions, pause, stopCapture)
 		if err != nil {
-			panic(fmt.Errorf("audio capture failed: %w", err))
-		}
-	}()
-
-	// Convert the raw audio to FLAC format and write directly to temp file
-	err = audio.ConvertAudio(ctx, logger, audio.ConvertAudioArgs{
-		Reader:       pipeReader,
-		Writer:       resultsWriter,
-		SourceFormat: "raw",
-		TargetFormat: "flac",
-		SampleRate:   selectedDevice.SampleRate,
-		Channels:     config.Capture.Channels,
-		BitDepth:     config.Capture.BitDepth,
+			return audio.CaptureStats{}, err
+		}
+		defer restoreTerminal()
+	} else {
+		// Key controls would consume audio when it is read from stdin
+		stopPauseControls := watchPauseControls(ctx, logger, pause, !audio.IsStdinSource(config.Capture.Device))
+		defer stopPauseControls()
+	}
+
+	recorder, err := audio.NewRecorder(logger, audio.RecorderArgs{
+		Backend:  config.Capture.Backend,
+		Device:   selectedDevice,
+		Channels: config.Capture.Channels,
+		BitDepth: config.Capture.BitDepth,
 	})
 	if err != nil {
-		return fmt.Errorf("audio conversion failed: %w", err)
+		return audio.CaptureStats{}, err
 	}
 
-	return nil
+	// Encode the raw audio to the capture format in the background, writing
+	// directly to the temp file and the archive
+	format := recorder.Format()
+	upload, err := startEncoder(ctx, logger, resultsWriter, format, uploadFormat(config.UploadProfile, format),
+		&config.Capture)
+	if err != nil {
+		return audio.CaptureStats{}, err
+	}
+	encoders := []*encoder{upload}
+	writers := multiWriteCloser{upload}
+	if archiveWriter != nil {
+		archive, err := startEncoder(ctx, logger, archiveWriter, format, format, config.archiveConfig())
+		if err != nil {
+			_ = upload.Close()
+			return audio.CaptureStats{}, err
+		}
+		encoders = append(encoders, archive)
+		writers = append(writers, archive)
+	}
+	slog.Debug("Uploading audio", "profile", config.UploadProfile, "format", uploadFormat(config.UploadProfile, format))
+
+	// The push-to-talk status line takes the place of the level meter
+	meter := meterOutput(&config.Capture)
+	if config.PushToTalk {
+		meter = nil
+	}
+	stats, captureErr := audio.LimitedCapture(captureCtx, logger, recorder, audio.LimitedCaptureArgs{
+		EnableAutoStop:      !config.Capture.NoAutoStop && !config.PushToTalk,
+		AutoStopEngine:      config.Capture.AutoStopEngine,
+		AutoStopThreshold:   config.Capture.AutoStopThreshold,
+		AutoStopMinDuration: durations.minSilence,
+		AutoStopHysteresis:  config.Capture.AutoStopHysteresis,
+		AutoStopNoiseMargin: config.Capture.AutoStopNoiseMargin,
+		WaitForSpeech:       config.Capture.WaitForSpeech,
+		PreRoll:             durations.preRoll,
+		MaxWait:             durations.maxWait,
+		TrimSilence:         config.Capture.TrimSilence,
+		TrimPad:             durations.trimPad,
+		Pause:               pause,
+		Meter:               meter,
+		Duration:            duration,
+		Writer:              writers,
+	})
+	var errs []error
+	for _, e := range encoders {
+		errs = append(errs, e.wait())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return audio.CaptureStats{}, fmt.Errorf("audio conversion failed: %w", err)
+	}
+	if captureErr != nil {
+		return audio.CaptureStats{}, fmt.Errorf("audio capture failed: %w", captureErr)
+	}
+
+	return stats, nil
 }
 
-// runTranscribe executes the audio transcription logic.
-func runTranscribe(baseConfig *Config, config *TranscribeConfig, inputFile string) error {
-	ctx := context.Background()
+// providerEndpoint is a transcription client guarded by an optional circuit breaker
+type providerEndpoint struct {
+	client  *openaix.Client
+	breaker *openaix.Breaker
+}
+
+// newProviderEndpoints creates the primary and, if configured, fallback provider endpoints
+func newProviderEndpoints(logger *slog.Logger, config *TranscribeConfig) ([]providerEndpoint, error) {
+	clients := []*openaix.Client{
+		openaix.NewClient(config.OpenAI.APIKey, config.OpenAI.BaseURL, config.AdditionalQueryParams),
+	}
+	if config.Fallback.BaseURL != "" {
+		apiKey := config.Fallback.APIKey
+		if apiKey == "" {
+			apiKey = config.OpenAI.APIKey
+		}
+		clients = append(clients, openaix.NewClient(apiKey, config.Fallback.BaseURL, config.AdditionalQueryParams))
+	}
+
+	var breakerArgs openaix.BreakerArgs
+	if !config.Breaker.Disabled {
+		cooldown, err := time.ParseDuration(config.Breaker.Cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("invalid breaker cooldown '%v', %w", config.Breaker.Cooldown, err)
+		}
+		statePath := config.Breaker.StateFile
+		if statePath == "" {
+			statePath, err = openaix.DefaultBreakerStatePath()
+			if err != nil {
+				logger.Warn("no cache directory for circuit breaker state, keeping it in memory", "error", err)
+			}
+		}
+		breakerArgs = openaix.BreakerArgs{
+			FailureThreshold: config.Breaker.FailureThreshold,
+			Cooldown:         cooldown,
+			StatePath:        statePath,
+		}
+	}
 
+	endpoints := make([]providerEndpoint, 0, len(clients))
+	for _, client := range clients {
+		endpoint := providerEndpoint{client: client}
+		if !config.Breaker.Disabled {
+			endpoint.breaker = openaix.NewBreaker(logger, client.BaseURL(), breakerArgs)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// transcribeWithFallback sends the request to each endpoint in order until one
+// succeeds. Endpoints with an open circuit are skipped without a request, and
+// only provider failures move on to the next endpoint.
+func transcribeWithFallback(
+	ctx context.Context,
+	logger *slog.Logger,
+	endpoints []providerEndpoint,
+	req openaix.TranscriptionRequest,
+) (*openaix.TranscriptionResponse, error) {
+	var errs []error
+	for i, endpoint := range endpoints {
+		if i > 0 {
+			logger.Warn("falling back to next provider endpoint", "endpoint", endpoint.client.BaseURL())
+		}
+		if endpoint.breaker != nil {
+			if err := endpoint.breaker.Allow(); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		t, err := endpoint.client.Transcribe(ctx, req)
+		if endpoint.breaker != nil {
+			endpoint.breaker.Record(err)
+		}
+		if err == nil {
+			return t, nil
+		}
+		errs = append(errs, err)
+		if !openaix.IsProviderFailure(err) {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// runTranscribe executes the audio transcription logic.
+func runTranscribe(
+	baseConfig *Config,
+	config *TranscribeConfig,
+	isSet func(string) bool,
+	inputFile string,
+) (retErr error) {
 	logger := baseConfig.getLogger()
 	slog.SetDefault(logger)
 
+	// The first interrupt stops the recording, which is still transcribed.
+	// The second, or the first after the recording, aborts the transcription request.
+	ctx, captureCtx, endCapture, stopInterrupts := handleInterrupts(context.Background(), logger)
+	defer stopInterrupts()
+	defer func() { retErr = interruptError(ctx, retErr) }()
+
+	// Amount of captured audio detected as speech, negative when unknown
+	speechDuration := time.Duration(-1)
+	minSpeechDuration, err := time.ParseDuration(config.MinSpeechDuration)
+	if err != nil {
+		return fmt.Errorf("invalid min speech duration: %w", err)
+	}
+
+	// Create the endpoints first, so that configuration errors are reported
+	// before anything is recorded
+	endpoints, err := newProviderEndpoints(logger, config)
+	if err != nil {
+		return err
+	}
+
 	var audioFilePath string
 	if config.NoCapture {
-		// Use the provided file directly
-		audioFilePath = inputFile
-		fmt.Println("Using provided audio file for transcription")
+		endCapture()
+		audioFilePath, err = prepareInput(ctx, logger, config, inputFile)
+		if err != nil {
+			return err
+		}
+		if audioFilePath != inputFile && !config.Debug {
+			defer func() { _ = os.Remove(audioFilePath) }()
+		}
 	} else {
 		// Create temp file and capture audio to it
-		tempFile, err := os.CreateTemp("", "sttrouter-capture-*.flac")
+		tempFile, err := os.CreateTemp("", "sttrouter-capture-*."+config.Capture.Format)
 		if err != nil {
 			return fmt.Errorf("failed to create temp file: %w", err)
 		}
@@ -184,8 +511,30 @@ func runTranscribe(baseConfig *Config, config *TranscribeConfig, inputFile strin
 		if config.Debug {
 			fmt.Printf("Debug: temp file created at %s\n", tempFile.Name())
 		}
-		fmt.Println("Audio capture started")
-		if err := runCaptureToWriter(baseConfig, config, tempFile); err != nil {
+		var archiveWriter io.Writer
+		if config.Archive != "" {
+			archiveFile, err := os.Create(config.Archive)
+			if err != nil {
+				return fmt.Errorf("failed to create archive file: %w", err)
+			}
+			defer func() { _ = archiveFile.Close() }()
+			archiveWriter = archiveFile
+		}
+		switch {
+		case config.PushToTalk:
+			// The push-to-talk prompt is shown instead
+		case config.Capture.WaitForSpeech:
+			fmt.Println("Waiting for speech")
+		default:
+			fmt.Println("Audio capture started")
+		}
+		stats, err := runCaptureToWriter(ctx, captureCtx, baseConfig, config, isSet, tempFile, archiveWriter)
+		endCapture()
+		if errors.Is(err, audio.ErrSpeechTimeout) {
+			fmt.Println("No speech detected, skipping transcription")
+			return fmt.Errorf("%w: %w", err, ErrNoSpeech)
+		}
+		if err != nil {
 			return err
 		}
 		// Flush by closing the file
@@ -193,23 +542,40 @@ func runTranscribe(baseConfig *Config, config *TranscribeConfig, inputFile strin
 			return fmt.Errorf("failed to close the temporary audio file, %w", err)
 		}
 		fmt.Println("Audio capture completed")
-		slog.Info("capture completed")
+		slog.Info("capture completed", "duration", stats.Duration, "speech_duration", stats.SpeechDuration)
 		audioFilePath = tempFile.Name()
-	}
+		speechDuration = stats.SpeechDuration
 
-	client := openaix.NewClient(config.OpenAI.APIKey, config.OpenAI.BaseURL, config.AdditionalQueryParams)
+		// Check audio quality before anything is uploaded
+		if err := checkQuality(&config.Capture.Quality, stats.Quality); err != nil {
+			return err
+		}
+
+		// Skip the provider call when nothing was said
+		if speechDuration < minSpeechDuration {
+			fmt.Println("No speech detected, skipping transcription")
+			return fmt.Errorf("%v of speech is below the minimum of %v: %w",
+				speechDuration, minSpeechDuration, ErrNoSpeech)
+		}
+	}
 
 	// Prepare transcription request
 	req := openaix.TranscriptionRequest{
-		File:           audioFilePath,
-		Model:          config.Model,
-		Language:       config.Language,
-		ResponseFormat: config.ResponseFormat,
-		Temperature:    config.Temperature,
+		File:                   audioFilePath,
+		Model:                  config.Model,
+		Language:               config.Language,
+		ResponseFormat:         config.ResponseFormat,
+		Temperature:            config.Temperature,
+		KnownSpeakerNames:      splitList(config.KnownSpeakerNames),
+		KnownSpeakerReferences: splitList(config.KnownSpeakerReferences),
+	}
+	if isDiarizationModel(config.Model) {
+		// Diarization models require a chunking strategy
+		req.ChunkingStrategy = "auto"
 	}
 
 	fmt.Println("Transcription started")
-	t, err := client.Transcribe(ctx, req)
+	t, err := transcribeWithFallback(ctx, logger, endpoints, req)
 	if err != nil {
 		return fmt.Errorf("failed to transcribe audio: %w", err)
 	}
@@ -217,8 +583,33 @@ func runTranscribe(baseConfig *Config, config *TranscribeConfig, inputFile strin
 	slog.Info("transcription completed")
 
 	transcription := t.Text
-	if !config.NoClipboard {
-		bs := bytes.NewBufferString(t.Text)
+	if config.OutputFormat == "speakers" {
+		transcription = formatSpeakerTranscript(t, req.KnownSpeakerNames)
+	}
+
+	// Guard against hallucinated transcriptions of silence
+	copyToClipboard := !config.NoClipboard
+	if config.Guard.Action != "none" {
+		guard := openaix.CheckTranscription(t, speechDuration, openaix.GuardArgs{
+			MaxNoSpeechProb:     config.Guard.MaxNoSpeechProb,
+			MaxCompressionRatio: config.Guard.MaxCompressionRatio,
+			MaxWordsPerSecond:   config.Guard.MaxWordsPerSecond,
+			Blocklist:           openaix.ParseBlocklist(config.Guard.Blocklist),
+		})
+		if guard.Suspect {
+			reasons := strings.Join(guard.Reasons, "; ")
+			if config.Guard.Action == "drop" {
+				slog.Warn("dropping suspect transcription", "reasons", reasons, "text", t.Text)
+				return fmt.Errorf("%s: %w", reasons, openaix.ErrSuspectTranscription)
+			}
+			slog.Warn("suspect transcription, not copying to clipboard", "reasons", reasons)
+			fmt.Fprintf(os.Stderr, "Warning: transcription may be a hallucination (%s)\n", reasons)
+			copyToClipboard = false
+		}
+	}
+
+	if copyToClipboard {
+		bs := bytes.NewBufferString(transcription)
 		if err := clipboard.CopyToClipboard(ctx, logger, bs); err != nil {
 			return fmt.Errorf("failed to copy transcription output to clipboard, %w", err)
 		}
@@ -230,7 +621,7 @@ func runTranscribe(baseConfig *Config, config *TranscribeConfig, inputFile strin
 	switch config.OutputFormat {
 	case "none":
 		// No output
-	case "text":
+	case "text", "speakers":
 		fmt.Println()
 		fmt.Println(transcription)
 	}
@@ -255,17 +646,64 @@ func NewTranscribeCommand() *cli.Command {
 		ArgsUsage: "[FILE]",
 		Description: `Capture audio from the microphone and transcribe it to text using Azure OpenAI's GPT-4o.
 
-Audio is captured from the microphone, converted to FLAC format,
-and sent to GPT-4o for transcription.
-
-Use --no-capture to skip audio capture and transcribe an existing audio file instead.
-When --no-capture is used, FILE is a required positional argument.
+Audio is captured from the microphone, converted to FLAC format (or WAV with
+--capture-format wav), and sent to GPT-4o for transcription.
+
+Speech models do not use more than 16 kHz mono, so by default the uploaded audio is mixed down
+to mono and resampled to 16 kHz. Use --upload-profile full to upload the audio as captured.
+Use --archive to also save the capture at full quality, for example for later review.
+The archive format is inferred from its extension and does not change the uploaded format.
+
+Use --no-capture to skip audio capture and transcribe an existing audio or video file instead.
+When --no-capture is used, FILE is a required positional argument. The format of FILE is
+detected from its contents, and files in an unknown format are rejected before any upload.
+MP3, Ogg and M4A audio is uploaded as is. WAV, video files (the first audio track is used),
+formats that the provider does not read such as AMR or AIFF, and files whose extension does
+not match their contents are converted to FLAC in the --upload-profile format first. With the
+speech profile, FLAC above 16 kHz or with several channels is converted too. WAV is converted
+in-process; other files are decoded with sox, or with ffmpeg for formats that sox cannot read.
+
+Captured audio is checked for quality problems such as a wrong device, low gain or clipping.
+Warnings are printed to stderr, and --capture-quality-strict aborts before uploading.
+
+If the auto-stop detector finds less than --min-speech-duration of speech in the captured audio,
+nothing is uploaded and the command exits with status 3.
+
+Press Ctrl-C (or send SIGTERM) to stop recording early; the audio captured so far is still
+transcribed. A second interrupt, or the first once recording has ended, aborts the transcription
+request and exits with status 130.
+
+Use --push-to-talk to record only while --push-to-talk-key (space by default) is held instead
+of stopping on silence. Terminals do not report key releases, so the key counts as released
+when its key repeats stop; raise --push-to-talk-repeat-delay if recording stops before the
+first repeat. With --push-to-talk-mode toggle, tap the key once to start and again to stop;
+presses closer together than the repeat delay count as key repeats.
+Push-to-talk reads the key from the terminal, so it works over SSH and inside tmux.
+The level meter is not shown in push-to-talk mode, where the status line shows the recording state.
 
 By default, transcription results are copied to the clipboard. Use --no-clipboard to disable this.
 
+Transcriptions that look like hallucinations of silence (empty text, known filler phrases such
+as "Thank you." from under half a second of detected speech, repeated phrases, high no-speech
+probability or more words than the detected speech allows) are dropped by default with exit
+status 4. Repeated phrases are detected per segment, or in 1000 byte windows of the text.
+Use --guard-action flag to print them with a warning without copying them to the clipboard,
+or --guard-action none to disable the check.
+
+Each provider endpoint is guarded by a circuit breaker. After --breaker-failure-threshold
+consecutive provider failures the circuit opens, and requests fail fast (or go to the
+--fallback-base-url endpoint) until --breaker-cooldown has passed and a probe succeeds.
+Breaker state is persisted between invocations and state changes are logged.
+
 Output format can be controlled with --output-format:
 - none: No stdout output
 - text: Plain text output to stdout (default)
+- speakers: One line per speaker turn, e.g. "[Speaker A 00:01:23] ...". Requires
+  --response-format diarized_json (or verbose_json for timestamps only)
+
+Diarization models such as gpt-4o-transcribe-diarize label each segment with a speaker.
+Use --known-speaker-names together with --known-speaker-references (2-10 second clips,
+one per name) to have segments labeled with names instead of letters.
 
 Examples:
   # Capture and transcribe from microphone (clipboard default)
@@ -277,8 +715,21 @@ Examples:
   # Capture and output to stdout in addition to clipboard
   sttrouter transcribe --api-key YOUR_KEY --output-format text
 
+  # Keep a full quality copy of the capture
+  sttrouter transcribe --api-key YOUR_KEY --archive meeting.flac
+
   # Transcribe an existing audio file
-  sttrouter transcribe --no-capture --api-key YOUR_KEY recording.flac`,
+  sttrouter transcribe --no-capture --api-key YOUR_KEY recording.flac
+
+  # Transcribe the audio track of a screen recording
+  sttrouter transcribe --no-capture --api-key YOUR_KEY recording.mov
+
+  # Label speakers in a recorded discussion
+  sttrouter transcribe --no-capture --model gpt-4o-transcribe-diarize \
+    --response-format diarized_json --output-format speakers meeting.flac
+
+  # Fall back to a second deployment when the primary region is down
+  sttrouter transcribe --openai-base-url https://east.example.com/... --fallback-base-url https://west.example.com/...`,
 		Flags: flags,
 		Action: func(c *cli.Context) error {
 			if transcribeConfig.NoCapture {
@@ -290,7 +741,6 @@ Examples:
 					return fmt.Errorf("no arguments expected when capturing from microphone")
 				}
 			}
-
 			if err := baseConfig.validate(); err != nil {
 				return err
 			}
@@ -299,7 +749,7 @@ Examples:
 				return err
 			}
 
-			return runTranscribe(&baseConfig, &transcribeConfig, c.Args().Get(0))
+			return runTranscribe(&baseConfig, &transcribeConfig, c.IsSet, c.Args().Get(0))
 		},
 	}
 }
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/sebnyberg/sttrouter/audio"
+)
+
+// Upload profiles
+const (
+	// uploadProfileSpeech uploads 16 kHz mono audio, which is all that speech models use
+	uploadProfileSpeech = "speech"
+	// uploadProfileFull uploads the audio as captured
+	uploadProfileFull = "full"
+)
+
+// speechUploadRate is the highest sample rate uploaded with the speech profile
+const speechUploadRate = 16000
+
+// uploadFormat returns the PCM format uploaded for audio captured in format
+func uploadFormat(profile string, format audio.WavFormat) audio.WavFormat {
+	if profile != uploadProfileSpeech {
+		return format
+	}
+	return audio.WavFormat{
+		SampleRate: min(format.SampleRate, speechUploadRate),
+		Channels:   1,
+		BitDepth:   min(format.BitDepth, 16),
+	}
+}
+
+// encoder implements io.WriteCloser and encodes the raw PCM written to it in
+// the background, converting it to another PCM format first if needed
+type encoder struct {
+	io.Writer
+	converter *audio.PCMConverter
+	pw        *io.PipeWriter
+	done      chan error
+}
+
+// startEncoder starts encoding raw PCM captured in the from format to w, in
+// the to format and the capture output format
+func startEncoder(
+	ctx context.Context,
+	logger *slog.Logger,
+	w io.Writer,
+	from, to audio.WavFormat,
+	config *CaptureConfig,
+) (*encoder, error) {
+	pr, pw := io.Pipe()
+	e := &encoder{Writer: pw, pw: pw, done: make(chan error, 1)}
+	if to != from {
+		converter, err := audio.NewPCMConverter(pw, from, to)
+		if err != nil {
+			return nil, err
+		}
+		e.Writer, e.converter = converter, converter
+	}
+
+	go func() {
+		err := audio.ConvertAudio(ctx, logger, audio.ConvertAudioArgs{
+			Reader:       pr,
+			Writer:       w,
+			SourceFormat: "raw",
+			TargetFormat: config.Format,
+			SampleRate:   to.SampleRate,
+			Channels:     to.Channels,
+			BitDepth:     to.BitDepth,
+			Codec:        config.Codec,
+			Bitrate:      config.Bitrate,
+			Quality:      config.CodecQuality,
+			Backend:      config.Backend,
+		})
+		// Fail further writes if the conversion stopped early
+		_ = pr.CloseWithError(err)
+		e.done <- err
+	}()
+	return e, nil
+}
+
+// Close ends the raw stream. Use wait for the encoding to finish.
+func (e *encoder) Close() error {
+	var err error
+	if e.converter != nil {
+		err = e.converter.Close()
+	}
+	return e.pw.CloseWithError(err)
+}
+
+// wait waits for the encoding to finish and returns its error
+func (e *encoder) wait() error {
+	return <-e.done
+}
+
+// multiWriteCloser writes to and closes all of its writers
+type multiWriteCloser []io.WriteCloser
+
+// Write implements io.Writer
+func (m multiWriteCloser) Write(p []byte) (int, error) {
+	for _, w := range m {
+		if _, err := w.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer
+func (m multiWriteCloser) Close() error {
+	var errs []error
+	for _, w := range m {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
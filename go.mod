@@ -3,11 +3,19 @@ module github.com/sebnyberg/sttrouter
 go 1.24.3
 
 require (
+	github.com/mewkiz/flac v1.0.14
 	github.com/sebnyberg/flagtags v0.0.0-20250929063118-2dc3260ab126
 	github.com/urfave/cli/v2 v2.27.7
+	golang.org/x/term v0.36.0
 )
 
-require github.com/xrash/smetrics v0.0.0-20250705151800-55b8f293f342 // indirect
+require (
+	github.com/icza/bitio v1.1.0 // indirect
+	github.com/mewkiz/pkg v0.0.0-20250417130911-3f050ff8c56d // indirect
+	github.com/mewpkg/term v0.0.0-20241026122259-37a80af23985 // indirect
+	github.com/xrash/smetrics v0.0.0-20250705151800-55b8f293f342 // indirect
+	golang.org/x/sys v0.37.0 // indirect
+)
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.7 // indirect
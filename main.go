@@ -24,12 +24,13 @@ func main() {
 			cmd.NewListDevicesCommand(),
 			cmd.NewCaptureCommand(),
 			cmd.NewTranscribeCommand(),
+			cmd.NewCalibrateCommand(),
 		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
 		logger.Error("Application error", "error", err)
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,239 @@
+package openaix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BreakerState represents the state of a circuit breaker
+type BreakerState string
+
+// Circuit breaker states
+const (
+	BreakerStateClosed   BreakerState = "closed"
+	BreakerStateOpen     BreakerState = "open"
+	BreakerStateHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus is a snapshot of the circuit breaker state for an endpoint
+type BreakerStatus struct {
+	Endpoint            string       `json:"endpoint"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            time.Time    `json:"opened_at,omitzero"`
+}
+
+// BreakerArgs holds the arguments for creating a circuit breaker
+type BreakerArgs struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// Cooldown is the time the circuit stays open before a half-open probe is allowed
+	Cooldown time.Duration
+	// StatePath is the file used to persist breaker state between invocations.
+	// State is kept in memory only when empty.
+	StatePath string
+}
+
+// Breaker is a circuit breaker guarding a single provider endpoint.
+//
+// Since each dictation runs as a separate process, the state is persisted to
+// StatePath so that an open circuit makes subsequent invocations fail fast
+// instead of waiting for the provider to time out again.
+type Breaker struct {
+	mu     sync.Mutex
+	log    *slog.Logger
+	args   BreakerArgs
+	status BreakerStatus
+	now    func() time.Time
+}
+
+// NewBreaker creates a circuit breaker for the endpoint, restoring any persisted state
+func NewBreaker(log *slog.Logger, endpoint string, args BreakerArgs) *Breaker {
+	b := &Breaker{
+		log:  log,
+		args: args,
+		status: BreakerStatus{
+			Endpoint: endpoint,
+			State:    BreakerStateClosed,
+		},
+		now: time.Now,
+	}
+	if args.StatePath == "" {
+		return b
+	}
+	statuses, err := readBreakerStatuses(args.StatePath)
+	if err != nil {
+		log.Warn("failed to read circuit breaker state, starting closed",
+			"path", args.StatePath,
+			"error", err)
+		return b
+	}
+	if status, ok := statuses[endpoint]; ok {
+		b.status = status
+	}
+	log.Debug("circuit breaker state", "endpoint", endpoint, "state", b.status.State)
+	return b
+}
+
+// DefaultBreakerStatePath returns the default location for persisted breaker state
+func DefaultBreakerStatePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sttrouter", "breakers.json"), nil
+}
+
+// Status returns a snapshot of the current breaker state
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// Allow reports whether a request may be sent to the endpoint. It returns
+// ErrCircuitOpen while the circuit is open and the cooldown has not elapsed.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.status.State != BreakerStateOpen {
+		return nil
+	}
+	if b.now().Sub(b.status.OpenedAt) < b.args.Cooldown {
+		return fmt.Errorf("%s: %w", b.status.Endpoint, ErrCircuitOpen)
+	}
+	b.transition(BreakerStateHalfOpen)
+	return nil
+}
+
+// Record records the outcome of a request to the endpoint. Only provider
+// failures count towards opening the circuit, see IsProviderFailure.
+//
+// Other errors end a half-open probe too: a rejected request shows that the
+// endpoint answers and closes the circuit, while a cancelled probe reopens it
+// without restarting the cooldown, so that the next request probes again.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.status.ConsecutiveFailures = 0
+		b.transition(BreakerStateClosed)
+		return
+	}
+	if !IsProviderFailure(err) {
+		if b.status.State != BreakerStateHalfOpen {
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			b.transition(BreakerStateOpen)
+			return
+		}
+		b.status.ConsecutiveFailures = 0
+		b.transition(BreakerStateClosed)
+		return
+	}
+
+	b.status.ConsecutiveFailures++
+	if b.status.State == BreakerStateHalfOpen || b.status.ConsecutiveFailures >= b.args.FailureThreshold {
+		b.status.OpenedAt = b.now()
+		b.transition(BreakerStateOpen)
+		return
+	}
+	b.save()
+}
+
+// transition moves the breaker to the given state, logging and persisting the change
+func (b *Breaker) transition(state BreakerState) {
+	if b.status.State != state {
+		b.log.Warn("circuit breaker state changed",
+			"endpoint", b.status.Endpoint,
+			"from", b.status.State,
+			"to", state,
+			"consecutive_failures", b.status.ConsecutiveFailures)
+	}
+	b.status.State = state
+	// OpenedAt is kept while half-open, for reopening after a cancelled probe
+	if state == BreakerStateClosed {
+		b.status.OpenedAt = time.Time{}
+	}
+	b.save()
+}
+
+// save persists the breaker state, merging with the state of other endpoints
+func (b *Breaker) save() {
+	if b.args.StatePath == "" {
+		return
+	}
+	statuses, err := readBreakerStatuses(b.args.StatePath)
+	if err != nil {
+		statuses = make(map[string]BreakerStatus)
+	}
+	statuses[b.status.Endpoint] = b.status
+	if err := writeBreakerStatuses(b.args.StatePath, statuses); err != nil {
+		b.log.Warn("failed to persist circuit breaker state",
+			"path", b.args.StatePath,
+			"error", err)
+	}
+}
+
+// IsProviderFailure reports whether err indicates that the provider endpoint
+// is unhealthy, as opposed to a rejected request or a cancellation by the user.
+func IsProviderFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrProviderUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func readBreakerStatuses(path string) (map[string]BreakerStatus, error) {
+	statuses := make(map[string]BreakerStatus)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return statuses, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func writeBreakerStatuses(path string, statuses map[string]BreakerStatus) error {
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so concurrent readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".breakers-*.json")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
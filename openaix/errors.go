@@ -0,0 +1,15 @@
+package openaix
+
+import "errors"
+
+// ErrProviderUnavailable indicates that the provider failed with a server-side or throttling error
+var ErrProviderUnavailable = errors.New("provider unavailable")
+
+// ErrRequestRejected indicates that the provider rejected the request (4xx other than throttling)
+var ErrRequestRejected = errors.New("request rejected")
+
+// ErrCircuitOpen indicates that the circuit breaker for the endpoint is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ErrSuspectTranscription indicates that a transcription is likely a hallucination
+var ErrSuspectTranscription = errors.New("suspect transcription")
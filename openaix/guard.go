@@ -0,0 +1,194 @@
+package openaix
+
+import (
+	"bytes"
+	"cmp"
+	"compress/zlib"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultHallucinationBlocklist contains phrases that transcription models are
+// known to produce for silent or near-silent audio.
+var DefaultHallucinationBlocklist = []string{
+	"thank you",
+	"thank you very much",
+	"thanks for watching",
+	"thank you for watching",
+	"please subscribe",
+	"bye",
+	"you",
+}
+
+const (
+	// blocklistMaxSpeech is the longest detected speech for which a blocklisted
+	// transcript is suspect. Longer speech was most likely said on purpose.
+	blocklistMaxSpeech = 500 * time.Millisecond
+	// compressionWindow is the size in bytes of the windows that the
+	// compression ratio is computed over. Ordinary prose compresses better
+	// the longer it is, so the ratio of a whole transcript is not comparable
+	// to a threshold.
+	compressionWindow = 1000
+)
+
+// GuardArgs holds the thresholds used to detect suspect transcriptions
+type GuardArgs struct {
+	// MaxNoSpeechProb is the highest average no-speech probability accepted for segmented responses
+	MaxNoSpeechProb float64
+	// MaxCompressionRatio is the highest text compression ratio accepted. High
+	// ratios indicate repeated phrases.
+	MaxCompressionRatio float64
+	// MaxWordsPerSecond is the highest number of words per second of detected
+	// speech that is accepted. Ignored when the speech duration is unknown.
+	MaxWordsPerSecond float64
+	// Blocklist contains phrases that are rejected when they make up the entire
+	// transcript, unless the detected speech is longer than blocklistMaxSpeech
+	Blocklist []string
+}
+
+// GuardResult is the outcome of checking a transcription
+type GuardResult struct {
+	Suspect bool
+	Reasons []string
+}
+
+func (r *GuardResult) add(format string, args ...any) {
+	r.Suspect = true
+	r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+}
+
+// CheckTranscription checks whether a transcription is likely to be a
+// hallucination rather than actual speech. The speech duration is the amount
+// of audio detected as speech during capture, or a negative value if unknown.
+func CheckTranscription(resp *TranscriptionResponse, speechDuration time.Duration, args GuardArgs) GuardResult {
+	var res GuardResult
+
+	text := normalizeTranscript(resp.Text)
+	if text == "" {
+		res.add("empty transcript")
+		return res
+	}
+
+	// Transcripts consisting only of blocklisted phrases, possibly repeated,
+	// from audio with little or unknown speech
+	if speechDuration < blocklistMaxSpeech {
+		for _, phrase := range args.Blocklist {
+			if isRepetitionOf(text, normalizeTranscript(phrase)) {
+				res.add("transcript matches blocklisted phrase %q", phrase)
+				break
+			}
+		}
+	}
+
+	// Segments are checked on their own, as the model does. Responses without
+	// segments are checked in windows of the text.
+	if args.MaxCompressionRatio > 0 {
+		if len(resp.Segments) > 0 {
+			for _, seg := range resp.Segments {
+				ratio := cmp.Or(seg.CompressionRatio, compressionRatio(seg.Text))
+				if ratio > args.MaxCompressionRatio {
+					res.add("segment compression ratio %.2f exceeds %.2f", ratio, args.MaxCompressionRatio)
+					break
+				}
+			}
+		} else if ratio := windowedCompressionRatio(resp.Text); ratio > args.MaxCompressionRatio {
+			res.add("compression ratio %.2f exceeds %.2f", ratio, args.MaxCompressionRatio)
+		}
+	}
+
+	if len(resp.Segments) > 0 && args.MaxNoSpeechProb > 0 {
+		var weighted, total float64
+		for _, seg := range resp.Segments {
+			d := max(seg.End-seg.Start, 0.01)
+			weighted += seg.NoSpeechProb * d
+			total += d
+		}
+		if p := weighted / total; p > args.MaxNoSpeechProb {
+			res.add("no-speech probability %.2f exceeds %.2f", p, args.MaxNoSpeechProb)
+		}
+	}
+
+	if speechDuration >= 0 && args.MaxWordsPerSecond > 0 {
+		words := len(strings.Fields(text))
+		seconds := max(speechDuration.Seconds(), 0.1)
+		if rate := float64(words) / seconds; rate > args.MaxWordsPerSecond {
+			res.add("%d words from %v of speech exceeds %.1f words per second",
+				words, speechDuration, args.MaxWordsPerSecond)
+		}
+	}
+
+	return res
+}
+
+// normalizeTranscript lowercases the text and strips punctuation so that
+// phrases can be compared regardless of formatting
+func normalizeTranscript(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// isRepetitionOf reports whether text consists only of one or more repetitions of phrase
+func isRepetitionOf(text, phrase string) bool {
+	words := strings.Fields(text)
+	phraseWords := strings.Fields(phrase)
+	if len(phraseWords) == 0 || len(words)%len(phraseWords) != 0 {
+		return false
+	}
+	for i, w := range words {
+		if w != phraseWords[i%len(phraseWords)] {
+			return false
+		}
+	}
+	return true
+}
+
+// compressionRatio returns the ratio between the size of the text and its
+// zlib-compressed size. Repetitive text compresses well and gets a high ratio.
+func compressionRatio(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return float64(len(s)) / float64(buf.Len())
+}
+
+// windowedCompressionRatio returns the highest compression ratio of windows
+// of compressionWindow bytes. The last window ends at the end of the text and
+// overlaps the one before it, so that no window is short.
+func windowedCompressionRatio(s string) float64 {
+	if len(s) <= compressionWindow {
+		return compressionRatio(s)
+	}
+	var ratio float64
+	for start := 0; start < len(s); start += compressionWindow {
+		start = min(start, len(s)-compressionWindow)
+		ratio = max(ratio, compressionRatio(s[start:start+compressionWindow]))
+	}
+	return ratio
+}
+
+// ParseBlocklist parses a comma-separated list of phrases, or returns the
+// default blocklist when the value is empty
+func ParseBlocklist(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return slices.Clone(DefaultHallucinationBlocklist)
+	}
+	var res []string
+	for _, phrase := range strings.Split(s, ",") {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			res = append(res, phrase)
+		}
+	}
+	return res
+}
@@ -3,13 +3,16 @@ package openaix
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -35,19 +38,67 @@ func NewClient(apiKey, baseURL, additionalQueryParams string) *Client {
 	}
 }
 
+// BaseURL returns the API base URL used by the client
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
 // TranscriptionRequest represents the request parameters for transcription
 type TranscriptionRequest struct {
-	File           string  `json:"file"`
-	Model          string  `json:"model"`
-	Language       string  `json:"language,omitempty"`
-	Prompt         string  `json:"prompt,omitempty"`
-	ResponseFormat string  `json:"response_format,omitempty"`
-	Temperature    float64 `json:"temperature,omitempty"`
+	File             string  `json:"file"`
+	Model            string  `json:"model"`
+	Language         string  `json:"language,omitempty"`
+	Prompt           string  `json:"prompt,omitempty"`
+	ResponseFormat   string  `json:"response_format,omitempty"`
+	Temperature      float64 `json:"temperature,omitempty"`
+	ChunkingStrategy string  `json:"chunking_strategy,omitempty"`
+	// KnownSpeakerNames labels speakers in diarized output, one per reference clip
+	KnownSpeakerNames []string `json:"known_speaker_names,omitempty"`
+	// KnownSpeakerReferences are paths to short audio clips of each known speaker
+	KnownSpeakerReferences []string `json:"known_speaker_references,omitempty"`
 }
 
 // TranscriptionResponse represents the response from the transcription API
 type TranscriptionResponse struct {
-	Text string `json:"text"`
+	Text     string                 `json:"text"`
+	Language string                 `json:"language,omitempty"`
+	Duration float64                `json:"duration,omitempty"`
+	Segments []TranscriptionSegment `json:"segments,omitempty"`
+}
+
+// TranscriptionSegment is a timed segment of the transcript. Speaker is only
+// set for diarized responses, and the quality metrics only for verbose ones.
+type TranscriptionSegment struct {
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Speaker          string  `json:"speaker,omitempty"`
+	AvgLogprob       float64 `json:"avg_logprob,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	NoSpeechProb     float64 `json:"no_speech_prob,omitempty"`
+}
+
+// isTextResponseFormat reports whether the API returns the transcript as plain text rather than JSON
+func isTextResponseFormat(format string) bool {
+	switch format {
+	case "text", "srt", "vtt":
+		return true
+	default:
+		return false
+	}
+}
+
+// speakerReferenceDataURL reads a speaker reference clip and encodes it as a data URL
+func speakerReferenceDataURL(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
 }
 
 // Transcribe transcribes an audio file using Azure OpenAI's GPT-4o
@@ -59,6 +110,15 @@ func (c *Client) Transcribe(ctx context.Context, req TranscriptionRequest) (*Tra
 	}
 	defer file.Close()
 
+	speakerReferences := make([]string, 0, len(req.KnownSpeakerReferences))
+	for _, path := range req.KnownSpeakerReferences {
+		ref, err := speakerReferenceDataURL(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read speaker reference: %w", err)
+		}
+		speakerReferences = append(speakerReferences, ref)
+	}
+
 	// Use buffered reader to reduce syscalls
 	bufferedFileReader := bufio.NewReader(file)
 
@@ -90,10 +150,23 @@ func (c *Client) Transcribe(ctx context.Context, req TranscriptionRequest) (*Tra
 		_, err = io.Copy(part, bufferedFileReader)
 		setErr(err)
 
-		// Add form fields - only send file and model to match working curl
+		// Add form fields - only send file, model and the fields needed for
+		// response formatting and diarization to match working curl
 		if req.Model != "" {
 			setErr(formWriter.WriteField("model", req.Model))
 		}
+		if req.ResponseFormat != "" {
+			setErr(formWriter.WriteField("response_format", req.ResponseFormat))
+		}
+		if req.ChunkingStrategy != "" {
+			setErr(formWriter.WriteField("chunking_strategy", req.ChunkingStrategy))
+		}
+		for _, name := range req.KnownSpeakerNames {
+			setErr(formWriter.WriteField("known_speaker_names[]", name))
+		}
+		for _, ref := range speakerReferences {
+			setErr(formWriter.WriteField("known_speaker_references[]", ref))
+		}
 
 		// Close the form writer
 		setErr(formWriter.Close())
@@ -126,10 +199,15 @@ func (c *Client) Transcribe(ctx context.Context, req TranscriptionRequest) (*Tra
 		return nil, fmt.Errorf("failed to write multipart data: %w", writeErr)
 	}
 
-	// Check status code
+	// Check status code. Server errors and throttling are reported as provider
+	// unavailability so that callers can count them towards circuit breaking.
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		sentinel := ErrRequestRejected
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			sentinel = ErrProviderUnavailable
+		}
+		return nil, fmt.Errorf("API request failed with status %d: %s: %w", resp.StatusCode, string(body), sentinel)
 	}
 
 	// Read the response body
@@ -138,6 +216,10 @@ func (c *Client) Transcribe(ctx context.Context, req TranscriptionRequest) (*Tra
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
+	if isTextResponseFormat(req.ResponseFormat) {
+		return &TranscriptionResponse{Text: strings.TrimSpace(string(body))}, nil
+	}
+
 	// Parse response
 	var transcriptionResp TranscriptionResponse
 	if err := json.Unmarshal(body, &transcriptionResp); err != nil {